@@ -0,0 +1,331 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"strings"
+)
+
+// Values of VmResource.Type.
+const (
+	VmResourceDeploymentVM = "DEPLOYMENT_VM"
+	VmResourceImportedVM   = "IMPORTED_VM"
+)
+
+// ActionAppliesToVirtualMachine reports whether the action can run on the
+// virtual machine. The action must be enabled, not deleted and available to
+// the user, and one of the filters of its VIRTUAL_MACHINE resource mappings
+// must match the virtual machine.
+//
+// A filter matches when all of its criteria do. Empty criteria, and criteria
+// listing "ALL" or "*", match any virtual machine. Otherwise:
+//
+//   - Type DEPLOYMENT_VM matches machines of a deployment and IMPORTED_VM the
+//     others;
+//   - AppProfiles, CloudRegions, CloudAccounts and Services match the id or
+//     name of the machine's application, region, cloud account and service;
+//   - CloudFamilyNames matches the cloud family and NodeStates the node
+//     status;
+//   - OsTypes matches when the OS name contains one of them;
+//   - CloudResourceMappings restricts the node states of the listed cloud
+//     families.
+//
+// Names and states are compared case-insensitively.
+//
+// These rules are the library's own reading of ActionResourceMapping: the API
+// does not document how CloudCenter evaluates the filters. The "ALL" and "*"
+// wildcards, case-insensitive comparison and substring matching of OsTypes
+// are assumptions, so the result can differ from the actions CloudCenter
+// offers for the virtual machine.
+func ActionAppliesToVirtualMachine(action *Action, vm *VirtualMachineDetails) bool {
+
+	if !actionUsable(action) || action.ActionResourceMappings == nil {
+		return false
+	}
+
+	for _, mapping := range *action.ActionResourceMappings {
+
+		if !strings.EqualFold(stringValue(mapping.Type), ResourceTypeVirtualMachine) || mapping.ActionResourceFilters == nil {
+			continue
+		}
+
+		for _, filter := range *mapping.ActionResourceFilters {
+			if filter.VmResource != nil && vmResourceMatches(filter.VmResource, vm) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ActionAppliesToJob reports whether the action can run on the deployment. The
+// action must be usable as for ActionAppliesToVirtualMachine and one of the
+// filters of its DEPLOYMENT resource mappings must match. A filter's
+// DeploymentResource is a comma-separated list of application ids or names;
+// empty, "ALL" or "*" matches any deployment. Like the virtual machine rules,
+// this is the library's reading of the filters, not documented behaviour.
+func ActionAppliesToJob(action *Action, job *Job) bool {
+
+	if !actionUsable(action) || action.ActionResourceMappings == nil {
+		return false
+	}
+
+	for _, mapping := range *action.ActionResourceMappings {
+
+		if !strings.EqualFold(stringValue(mapping.Type), ResourceTypeDeployment) || mapping.ActionResourceFilters == nil {
+			continue
+		}
+
+		for _, filter := range *mapping.ActionResourceFilters {
+
+			var apps []string
+			for _, app := range strings.Split(stringValue(filter.DeploymentResource), ",") {
+				if app = strings.TrimSpace(app); app != "" {
+					apps = append(apps, app)
+				}
+			}
+
+			if matchesAny(apps, stringValue(job.AppId), stringValue(job.AppName)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ActionsForVirtualMachine returns the actions that can run on the virtual
+// machine, keeping their order.
+func ActionsForVirtualMachine(actions []Action, vm *VirtualMachineDetails) []Action {
+
+	eligible := []Action{}
+
+	for i := range actions {
+		if ActionAppliesToVirtualMachine(&actions[i], vm) {
+			eligible = append(eligible, actions[i])
+		}
+	}
+
+	return eligible
+}
+
+// ActionsForJob returns the actions that can run on the deployment, keeping
+// their order.
+func ActionsForJob(actions []Action, job *Job) []Action {
+
+	eligible := []Action{}
+
+	for i := range actions {
+		if ActionAppliesToJob(&actions[i], job) {
+			eligible = append(eligible, actions[i])
+		}
+	}
+
+	return eligible
+}
+
+// VirtualMachinesForAction returns the virtual machines the action can run on,
+// keeping their order.
+func VirtualMachinesForAction(action *Action, vms []VirtualMachineDetails) []VirtualMachineDetails {
+
+	eligible := []VirtualMachineDetails{}
+
+	for i := range vms {
+		if ActionAppliesToVirtualMachine(action, &vms[i]) {
+			eligible = append(eligible, vms[i])
+		}
+	}
+
+	return eligible
+}
+
+// GetVirtualMachineActions returns the actions that can currently run on the
+// virtual machine. Every page of actions is read.
+func (s *Client) GetVirtualMachineActions(ctx context.Context, virtualMachineId int) ([]Action, error) {
+
+	vm, err := s.GetVirtualMachine(virtualMachineId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	actions, err := s.getAllActions()
+	if err != nil {
+		return nil, err
+	}
+
+	return ActionsForVirtualMachine(actions, vm), nil
+}
+
+// GetJobActions returns the actions that can currently run on the deployment.
+// Every page of actions is read.
+func (s *Client) GetJobActions(ctx context.Context, jobId int) ([]Action, error) {
+
+	job, err := s.GetJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	actions, err := s.getAllActions()
+	if err != nil {
+		return nil, err
+	}
+
+	return ActionsForJob(actions, job), nil
+}
+
+// GetActionVirtualMachines returns the virtual machines the action can run on
+// right now, given their current node status. Every page of virtual machines
+// is read.
+func (s *Client) GetActionVirtualMachines(ctx context.Context, actionId int) ([]VirtualMachineDetails, error) {
+
+	action, err := s.GetAction(actionId)
+	if err != nil {
+		return nil, err
+	}
+
+	vms, err := s.getAllVirtualMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return VirtualMachinesForAction(action, vms), nil
+}
+
+// ActionTargets returns the virtual machines as targets for ExecuteAction.
+func ActionTargets(vms []VirtualMachineDetails) []ResourceTarget {
+
+	targets := make([]ResourceTarget, 0, len(vms))
+
+	for _, vm := range vms {
+		targets = append(targets, ResourceTarget{ResourceId: stringValue(vm.Id), ResourceType: ResourceTypeVirtualMachine})
+	}
+
+	return targets
+}
+
+func actionUsable(action *Action) bool {
+
+	switch {
+	case action.Enabled != nil && !*action.Enabled:
+	case action.Deleted != nil && *action.Deleted:
+	case action.IsAvailableToUser != nil && !*action.IsAvailableToUser:
+	default:
+		return true
+	}
+
+	return false
+}
+
+func vmResourceMatches(resource *VmResource, vm *VirtualMachineDetails) bool {
+
+	deployed := stringValue(vm.JobId) != ""
+
+	switch strings.ToUpper(stringValue(resource.Type)) {
+	case VmResourceDeploymentVM:
+		if !deployed {
+			return false
+		}
+	case VmResourceImportedVM:
+		if deployed {
+			return false
+		}
+	}
+
+	state := stringValue(vm.NodeStatus)
+	if state == "" {
+		state = stringValue(vm.Status)
+	}
+
+	switch {
+	case !matchesAny(stringSlice(resource.AppProfiles), stringValue(vm.AppId), stringValue(vm.AppName)):
+	case !matchesAny(stringSlice(resource.CloudRegions), stringValue(vm.RegionId), stringValue(vm.RegionName), stringValue(vm.RegionDisplayName)):
+	case !matchesAny(stringSlice(resource.CloudAccounts), stringValue(vm.CloudAccountId), stringValue(vm.CloudAccountName)):
+	case !matchesAny(stringSlice(resource.Services), stringValue(vm.ServiceId), stringValue(vm.ServiceName)):
+	case !matchesAny(stringSlice(resource.CloudFamilyNames), stringValue(vm.CloudFamily)):
+	case !matchesAny(stringSlice(resource.NodeStates), state):
+	case !matchesOsType(stringSlice(resource.OsTypes), stringValue(vm.OSName)):
+	default:
+		if resource.CloudResourceMappings != nil {
+			for _, mapping := range *resource.CloudResourceMappings {
+				if strings.EqualFold(stringValue(mapping.CloudFamily), stringValue(vm.CloudFamily)) && !matchesAny(stringSlice(mapping.NodeStates), state) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// matchesAny reports whether one of values is in allowed, case-insensitively.
+// An empty allowed list, or one containing "ALL" or "*", matches anything.
+func matchesAny(allowed []string, values ...string) bool {
+
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, a := range allowed {
+
+		if a == "*" || strings.EqualFold(a, "ALL") {
+			return true
+		}
+
+		for _, value := range values {
+			if value != "" && strings.EqualFold(a, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchesOsType(osTypes []string, osName string) bool {
+
+	if matchesAny(osTypes, osName) {
+		return true
+	}
+
+	for _, osType := range osTypes {
+		if osType != "" && strings.Contains(strings.ToLower(osName), strings.ToLower(osType)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringSlice(values *[]string) []string {
+
+	if values == nil {
+		return nil
+	}
+
+	return *values
+}
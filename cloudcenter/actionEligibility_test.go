@@ -0,0 +1,183 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testVmAction(resource VmResource) *Action {
+	return &Action{
+		Name: String("restart"),
+		ActionResourceMappings: &[]ActionResourceMapping{{
+			Type:                  String(ResourceTypeVirtualMachine),
+			ActionResourceFilters: &[]ActionResourceFilter{{VmResource: &resource}},
+		}},
+	}
+}
+
+func TestActionAppliesToVirtualMachine(t *testing.T) {
+
+	vm := &VirtualMachineDetails{
+		JobId:            String("10"),
+		AppId:            String("7"),
+		AppName:          String("WordPress"),
+		RegionId:         String("2"),
+		RegionName:       String("us-east-1"),
+		CloudAccountName: String("AWS production"),
+		CloudFamily:      String("Amazon"),
+		NodeStatus:       String("Running"),
+		OSName:           String("CentOS Linux 7"),
+	}
+
+	imported := &VirtualMachineDetails{CloudFamily: String("Amazon"), Status: String("Stopped")}
+
+	disabled := testVmAction(VmResource{})
+	disabled.Enabled = Bool(false)
+
+	deleted := testVmAction(VmResource{})
+	deleted.Deleted = Bool(true)
+
+	deploymentMapping := &Action{ActionResourceMappings: &[]ActionResourceMapping{{
+		Type:                  String(ResourceTypeDeployment),
+		ActionResourceFilters: &[]ActionResourceFilter{{DeploymentResource: String("")}},
+	}}}
+
+	tests := []struct {
+		name   string
+		action *Action
+		vm     *VirtualMachineDetails
+		want   bool
+	}{
+		{"empty filter", testVmAction(VmResource{}), vm, true},
+		{"disabled action", disabled, vm, false},
+		{"deleted action", deleted, vm, false},
+		{"deployment mapping only", deploymentMapping, vm, false},
+		{"deployment vm", testVmAction(VmResource{Type: String(VmResourceDeploymentVM)}), vm, true},
+		{"deployment vm rejects imported", testVmAction(VmResource{Type: String(VmResourceDeploymentVM)}), imported, false},
+		{"imported vm", testVmAction(VmResource{Type: String(VmResourceImportedVM)}), imported, true},
+		{"app by name, any case", testVmAction(VmResource{AppProfiles: &[]string{"wordpress"}}), vm, true},
+		{"app by id", testVmAction(VmResource{AppProfiles: &[]string{"7"}}), vm, true},
+		{"other app", testVmAction(VmResource{AppProfiles: &[]string{"Drupal"}}), vm, false},
+		{"ALL wildcard", testVmAction(VmResource{AppProfiles: &[]string{"All"}}), vm, true},
+		{"star wildcard", testVmAction(VmResource{CloudRegions: &[]string{"*"}}), vm, true},
+		{"region by name", testVmAction(VmResource{CloudRegions: &[]string{"US-EAST-1"}}), vm, true},
+		{"other account", testVmAction(VmResource{CloudAccounts: &[]string{"AWS staging"}}), vm, false},
+		{"os type contained in os name", testVmAction(VmResource{OsTypes: &[]string{"centos"}}), vm, true},
+		{"other os type", testVmAction(VmResource{OsTypes: &[]string{"Windows"}}), vm, false},
+		{"node state", testVmAction(VmResource{NodeStates: &[]string{"RUNNING"}}), vm, true},
+		{"status used without node status", testVmAction(VmResource{NodeStates: &[]string{"Stopped"}}), imported, true},
+		{"all criteria must match", testVmAction(VmResource{AppProfiles: &[]string{"WordPress"}, NodeStates: &[]string{"Stopped"}}), vm, false},
+		{
+			name: "cloud resource mapping restricts its family",
+			action: testVmAction(VmResource{CloudResourceMappings: &[]CloudResourceMapping{
+				{CloudFamily: String("amazon"), NodeStates: &[]string{"Stopped"}},
+			}}),
+			vm:   vm,
+			want: false,
+		},
+		{
+			name: "cloud resource mapping of another family",
+			action: testVmAction(VmResource{CloudResourceMappings: &[]CloudResourceMapping{
+				{CloudFamily: String("Azure"), NodeStates: &[]string{"Stopped"}},
+			}}),
+			vm:   vm,
+			want: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ActionAppliesToVirtualMachine(test.action, test.vm); got != test.want {
+				t.Errorf("ActionAppliesToVirtualMachine = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestActionAppliesToJob(t *testing.T) {
+
+	job := &Job{AppId: String("7"), AppName: String("WordPress")}
+
+	action := func(deploymentResource string) *Action {
+		return &Action{ActionResourceMappings: &[]ActionResourceMapping{{
+			Type:                  String("deployment"),
+			ActionResourceFilters: &[]ActionResourceFilter{{DeploymentResource: String(deploymentResource)}},
+		}}}
+	}
+
+	unavailable := action("")
+	unavailable.IsAvailableToUser = Bool(false)
+
+	tests := []struct {
+		name   string
+		action *Action
+		want   bool
+	}{
+		{"empty", action(""), true},
+		{"app id in list", action("3, 7"), true},
+		{"app name", action("wordpress"), true},
+		{"ALL", action("ALL"), true},
+		{"other apps", action("3,Drupal"), false},
+		{"unavailable", unavailable, false},
+		{"vm mapping only", testVmAction(VmResource{}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ActionAppliesToJob(test.action, job); got != test.want {
+				t.Errorf("ActionAppliesToJob = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetJobActionsReadsEveryPage(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/jobs/10":
+			fmt.Fprint(w, `{"id":"10","appId":"7"}`)
+		case r.URL.Path == "/v1/actions":
+			page := r.URL.Query().Get("page")
+			fmt.Fprintf(w, `{"totalPages":2,"actionJaxbs":[{"id":"%s","actionResourceMappings":[{"type":"DEPLOYMENT"}]},{"id":"x%s","actionResourceMappings":[{"type":"DEPLOYMENT","actionResourceFilters":[{"deploymentResource":"7"}]}]}]}`, page, page)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient("user", "key", srv.URL, false, "", "", "")
+
+	actions, err := client.GetJobActions(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, action := range actions {
+		ids = append(ids, stringValue(action.Id))
+	}
+
+	if fmt.Sprint(ids) != "[x0 x1]" {
+		t.Errorf("actions = %v, want the matching action of each page", ids)
+	}
+}
@@ -0,0 +1,402 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActionExecutionRequest is the body of an action execution.
+type ActionExecutionRequest struct {
+	ResourceIds      *[]ActionExecutionResource `json:"resourceIds,omitempty"`
+	ActionParameters *[]ActionParameter         `json:"actionParameters,omitempty"`
+}
+
+type ActionExecutionResource struct {
+	ResourceId   *string `json:"resourceId,omitempty"`
+	ResourceType *string `json:"resourceType,omitempty"`
+}
+
+// ActionTargetResult is the outcome of an action on one target. Operation is
+// the last known status of the operation running the action on the target,
+// shared with the other targets when the action runs on all of them at once.
+type ActionTargetResult struct {
+	Target    ResourceTarget   `json:"target"`
+	Operation *OperationStatus `json:"operation,omitempty"`
+	Err       error            `json:"-"`
+}
+
+// ActionExecution is an action started by ExecuteAction. Wait polls its
+// operations until they finish and fills in the per-target results.
+type ActionExecution struct {
+	ActionId string               `json:"actionId"`
+	Results  []ActionTargetResult `json:"results"`
+
+	client     *Client
+	operations []actionOperation
+}
+
+type actionOperation struct {
+	status  *OperationStatus
+	results []int
+}
+
+// ExecuteAction validates params against the action's ActionCustomParamSpecs
+// and starts the action on the targets. Actions supporting bulk operations
+// run as a single operation on all targets, others as one operation per
+// target. Targets that fail to start are reported in Results with Err set;
+// ExecuteAction itself fails only when nothing could be started. When ctx is
+// done after some targets were started, the execution is returned along with
+// ctx's error and the targets not started have Err set.
+func (s *Client) ExecuteAction(ctx context.Context, actionId int, targets []ResourceTarget, params map[string]string) (*ActionExecution, error) {
+
+	if len(targets) == 0 {
+		return nil, errors.New("No targets to execute the action on")
+	}
+
+	for _, target := range targets {
+		if err := target.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	action, err := s.GetAction(actionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if action.Enabled != nil && !*action.Enabled {
+		return nil, fmt.Errorf("Action %s is disabled", stringValue(action.Name))
+	}
+
+	parameters, err := ValidateActionParams(action, params)
+	if err != nil {
+		return nil, err
+	}
+
+	execution := &ActionExecution{
+		ActionId: strconv.Itoa(actionId),
+		client:   s,
+	}
+
+	for _, target := range targets {
+		execution.Results = append(execution.Results, ActionTargetResult{Target: target})
+	}
+
+	var batches [][]int
+	if action.BulkOperationSupported != nil && *action.BulkOperationSupported {
+		batch := make([]int, len(targets))
+		for i := range targets {
+			batch[i] = i
+		}
+		batches = append(batches, batch)
+	} else {
+		for i := range targets {
+			batches = append(batches, []int{i})
+		}
+	}
+
+	started := 0
+
+	for n, batch := range batches {
+
+		if err := ctx.Err(); err != nil {
+			if started == 0 {
+				return nil, err
+			}
+			for _, rest := range batches[n:] {
+				for _, i := range rest {
+					execution.Results[i].Err = err
+				}
+			}
+			return execution, err
+		}
+
+		var resources []ActionExecutionResource
+		for _, i := range batch {
+			resources = append(resources, ActionExecutionResource{
+				ResourceId:   String(targets[i].ResourceId),
+				ResourceType: String(targets[i].ResourceType),
+			})
+		}
+
+		status, err := s.startAction(actionId, &ActionExecutionRequest{
+			ResourceIds:      &resources,
+			ActionParameters: &parameters,
+		})
+
+		for _, i := range batch {
+			execution.Results[i].Operation = status
+			execution.Results[i].Err = err
+		}
+
+		if err != nil {
+			continue
+		}
+
+		started++
+		execution.operations = append(execution.operations, actionOperation{status: status, results: batch})
+	}
+
+	if started == 0 {
+		return nil, execution.Results[0].Err
+	}
+
+	return execution, nil
+}
+
+// Wait polls the operations of the execution concurrently until they finish,
+// updating Results, and returns an error when any target failed.
+func (e *ActionExecution) Wait(ctx context.Context, pollInterval time.Duration) error {
+
+	var mu sync.Mutex
+
+	forEachConcurrently(ctx, len(e.operations), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		operation := e.operations[i]
+
+		status, err := e.client.WaitForOperation(ctx, operation.status, pollInterval)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, result := range operation.results {
+			if status != nil {
+				e.Results[result].Operation = status
+			}
+			e.Results[result].Err = err
+		}
+
+		// keep waiting on the other operations
+		return nil
+	})
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if failed := e.Failed(); len(failed) > 0 {
+		return fmt.Errorf("Action %s failed on %d of %d targets: %s", e.ActionId, len(failed), len(e.Results), failed[0].Err)
+	}
+
+	return nil
+}
+
+// Failed returns the results of the targets the action failed on, so far.
+func (e *ActionExecution) Failed() []ActionTargetResult {
+
+	var failed []ActionTargetResult
+
+	for _, result := range e.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// ValidateActionParams checks params against the action's custom parameter
+// specs and returns the action parameters to send, with defaults filled in.
+// Parameters must be declared by a spec or be a custom parameter of the
+// action. Values are checked against the spec's type, value list and value
+// constraint; params the spec does not let users edit must keep their default.
+func ValidateActionParams(action *Action, params map[string]string) ([]ActionParameter, error) {
+
+	specs := make(map[string]ActionCustomParamSpec)
+	if action.ActionCustomParamSpecs != nil {
+		for _, spec := range *action.ActionCustomParamSpecs {
+			specs[stringValue(spec.ParamName)] = spec
+		}
+	}
+
+	custom := make(map[string]bool)
+	if action.ActionParameters != nil {
+		for _, parameter := range *action.ActionParameters {
+			if parameter.CustomParam != nil && *parameter.CustomParam {
+				custom[stringValue(parameter.ParamName)] = true
+			}
+		}
+	}
+
+	var problems []string
+
+	for name := range params {
+		if _, ok := specs[name]; !ok && !custom[name] {
+			problems = append(problems, name+" is not a parameter of the action")
+		}
+	}
+
+	values := make(map[string]string)
+	for name, value := range params {
+		values[name] = value
+	}
+
+	for name, spec := range specs {
+
+		value, set := params[name]
+		def := stringValue(spec.DefaultValue)
+
+		if set && spec.UserEditable != nil && !*spec.UserEditable && value != def {
+			problems = append(problems, name+" cannot be changed")
+			continue
+		}
+
+		if !set || value == "" {
+			value = def
+			if value == "" {
+				if spec.Optional == nil || !*spec.Optional {
+					problems = append(problems, name+" is required")
+				}
+				continue
+			}
+			values[name] = value
+		}
+
+		if err := validateActionParamValue(spec, value); err != nil {
+			problems = append(problems, name+" "+err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("Invalid parameters for action %s: %s", stringValue(action.Name), strings.Join(problems, "; "))
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := []ActionParameter{}
+	for _, name := range names {
+		parameters = append(parameters, ActionParameter{
+			ParamName:  String(name),
+			ParamValue: String(values[name]),
+		})
+	}
+
+	return parameters, nil
+}
+
+func validateActionParamValue(spec ActionCustomParamSpec, value string) error {
+
+	items := []string{value}
+	if spec.MultiselectSupported != nil && *spec.MultiselectSupported {
+		items = strings.Split(value, ",")
+	}
+
+	if list := stringValue(spec.ValueList); list != "" {
+		allowed := make(map[string]bool)
+		for _, item := range strings.Split(list, ",") {
+			allowed[strings.TrimSpace(item)] = true
+		}
+		for _, item := range items {
+			if !allowed[strings.TrimSpace(item)] {
+				return errors.New("must be one of " + list)
+			}
+		}
+	}
+
+	switch strings.ToLower(stringValue(spec.Type)) {
+	case "number":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		if c := spec.ValueConstraint; c != nil {
+			if c.MinValue != nil && number < float64(*c.MinValue) {
+				return fmt.Errorf("must be at least %d", *c.MinValue)
+			}
+			if c.MaxValue != nil && number > float64(*c.MaxValue) {
+				return fmt.Errorf("must be at most %d", *c.MaxValue)
+			}
+		}
+		return nil
+	case "boolean", "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("must be true or false")
+		}
+		return nil
+	}
+
+	c := spec.ValueConstraint
+	if c == nil {
+		return nil
+	}
+
+	if c.MaxLength != nil && *c.MaxLength > 0 && int64(len(value)) > *c.MaxLength {
+		return fmt.Errorf("must be at most %d characters long", *c.MaxLength)
+	}
+
+	if c.AllowSpaces != nil && !*c.AllowSpaces && strings.ContainsAny(value, " \t") {
+		return errors.New("must not contain spaces")
+	}
+
+	if regex := stringValue(c.Regex); regex != "" {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return errors.New("has an invalid regex " + regex)
+		}
+		if !re.MatchString(value) {
+			return errors.New("must match " + regex)
+		}
+	}
+
+	return nil
+}
+
+func (s *Client) startAction(actionId int, request *ActionExecutionRequest) (*OperationStatus, error) {
+
+	var data OperationStatus
+
+	url := fmt.Sprintf(s.BaseURL + "/v1/actions/" + strconv.Itoa(actionId))
+
+	j, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(j))
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := s.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(bytes, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
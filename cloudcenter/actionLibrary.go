@@ -0,0 +1,466 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ActionLibrary is a portable set of user-defined actions. Server-generated
+// fields are left out and secrets are replaced by ${CCSECRET_NAME}
+// placeholders, which ImportActions resolves from a secrets file or the
+// environment.
+type ActionLibrary struct {
+	Actions []Action `json:"actions"`
+}
+
+// ActionImportOptions controls ImportActions.
+type ActionImportOptions struct {
+
+	// Secrets are the values of placeholders, e.g. loaded with
+	// LoadActionSecrets. Placeholders missing from Secrets are looked up in
+	// the environment.
+	Secrets map[string]string
+
+	// PlanOnly reports what would be created and updated without changing
+	// anything.
+	PlanOnly bool
+
+	// Output, when set, receives the actions to create and update, with the
+	// names of their changed fields, before they are applied.
+	Output io.Writer
+}
+
+type ActionImportResult struct {
+	Created   []string `json:"created,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+}
+
+// actionIgnoredFields are generated by CloudCenter or specific to one
+// instance.
+var actionIgnoredFields = map[string]bool{
+	"id":                true,
+	"resource":          true,
+	"perms":             true,
+	"lastUpdatedTime":   true,
+	"owner":             true,
+	"deleted":           true,
+	"systemDefined":     true,
+	"isAvailableToUser": true,
+}
+
+// secretPrefix starts the name of every secret placeholder, so that ${VAR}
+// references in scripts and other strings are never taken for placeholders.
+const secretPrefix = "CCSECRET_"
+
+var secretPlaceholder = regexp.MustCompile(`\$\{(` + secretPrefix + `[A-Za-z0-9_]+)\}`)
+
+// ExportActions returns the user-defined actions, sorted by name, with the
+// web service usernames and passwords of their custom parameters, and the
+// defaults of password parameters, replaced by placeholders. System-defined
+// and deleted actions are left out. Every page of actions is read.
+func (s *Client) ExportActions(ctx context.Context) (*ActionLibrary, error) {
+
+	actions, err := s.getAllActions()
+	if err != nil {
+		return nil, err
+	}
+
+	library := &ActionLibrary{Actions: []Action{}}
+
+	for _, action := range actions {
+
+		if action.SystemDefined != nil && *action.SystemDefined || action.Deleted != nil && *action.Deleted {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		exported, err := exportAction(action)
+		if err != nil {
+			return nil, err
+		}
+
+		library.Actions = append(library.Actions, exported)
+	}
+
+	sort.SliceStable(library.Actions, func(i, j int) bool {
+		return stringValue(library.Actions[i].Name) < stringValue(library.Actions[j].Name)
+	})
+
+	return library, nil
+}
+
+// LoadActionLibrary reads an action library from a JSON or YAML (.yaml, .yml)
+// file.
+func LoadActionLibrary(filename string) (*ActionLibrary, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var library ActionLibrary
+
+	err = unmarshalByExtension(filename, b, &library)
+	if err != nil {
+		return nil, err
+	}
+
+	return &library, nil
+}
+
+// WriteFile writes the library as YAML when filename ends in .yaml or .yml and
+// as JSON otherwise.
+func (l *ActionLibrary) WriteFile(filename string) error {
+
+	var b []byte
+	var err error
+
+	if isYAMLFile(filename) {
+		b, err = marshalYAML(l)
+	} else {
+		var buf bytes.Buffer
+		err = writeIndentedJSON(&buf, l)
+		b = buf.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// Placeholders returns the names of the placeholders used in the library,
+// sorted.
+func (l *ActionLibrary) Placeholders() []string {
+
+	seen := make(map[string]bool)
+
+	j, _ := json.Marshal(l)
+	for _, match := range secretPlaceholder.FindAllStringSubmatch(string(j), -1) {
+		seen[match[1]] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadActionSecrets reads placeholder values from a JSON or YAML (.yaml, .yml)
+// file holding an object of names to values.
+func LoadActionSecrets(filename string) (map[string]string, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string)
+
+	err = unmarshalByExtension(filename, b, &secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// ImportActions resolves the library's placeholders and creates or updates
+// its actions, matched by name. Every placeholder must resolve before anything
+// is changed. Only the fields set in an action are compared and updated.
+// Every page of the existing actions is read to match them.
+func (s *Client) ImportActions(ctx context.Context, library *ActionLibrary, opts ActionImportOptions) (*ActionImportResult, error) {
+
+	if err := library.checkSecrets(opts.Secrets); err != nil {
+		return nil, err
+	}
+
+	live, err := s.getAllActions()
+	if err != nil {
+		return nil, err
+	}
+
+	liveByName := make(map[string]Action)
+	for _, action := range live {
+		if action.Deleted != nil && *action.Deleted {
+			continue
+		}
+		liveByName[stringValue(action.Name)] = action
+	}
+
+	type importStep struct {
+		name    string
+		desired Action
+		current *Action
+		fields  []string
+	}
+
+	var steps []importStep
+	result := &ActionImportResult{}
+	seen := make(map[string]bool)
+
+	for _, action := range library.Actions {
+
+		if nonzero(action.Name) {
+			return nil, errors.New("Action.Name is missing")
+		}
+
+		name := *action.Name
+		if seen[name] {
+			return nil, errors.New("More than one action is named " + name)
+		}
+		seen[name] = true
+
+		desired, err := resolveActionSecrets(action, opts.Secrets)
+		if err != nil {
+			return nil, err
+		}
+
+		current, ok := liveByName[name]
+		if !ok {
+			steps = append(steps, importStep{name: name, desired: desired})
+			continue
+		}
+
+		if current.SystemDefined != nil && *current.SystemDefined {
+			return nil, errors.New("Action " + name + " is system-defined and cannot be updated")
+		}
+
+		var fields []string
+		for _, change := range desiredFieldChanges(current, desired, actionIgnoredFields) {
+			fields = append(fields, change.Field)
+		}
+
+		if len(fields) == 0 {
+			result.Unchanged = append(result.Unchanged, name)
+			continue
+		}
+
+		steps = append(steps, importStep{name: name, desired: desired, current: &current, fields: fields})
+	}
+
+	if opts.Output != nil {
+		for _, step := range steps {
+			if step.current == nil {
+				fmt.Fprintf(opts.Output, "+ action %s\n", step.name)
+				continue
+			}
+			// values are not shown as they may hold secrets
+			fmt.Fprintf(opts.Output, "~ action %s\n    %s\n", step.name, strings.Join(step.fields, ", "))
+		}
+		if len(steps) == 0 {
+			fmt.Fprintln(opts.Output, "No changes")
+		}
+	}
+
+	for _, step := range steps {
+
+		if opts.PlanOnly {
+			if step.current == nil {
+				result.Created = append(result.Created, step.name)
+			} else {
+				result.Updated = append(result.Updated, step.name)
+			}
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if step.current == nil {
+			if _, err := s.AddAction(&step.desired); err != nil {
+				return result, fmt.Errorf("Creating action %s failed: %s", step.name, err)
+			}
+			result.Created = append(result.Created, step.name)
+			continue
+		}
+
+		var action Action
+		if err := mergeDesired(*step.current, step.desired, &action, actionIgnoredFields); err != nil {
+			return result, err
+		}
+
+		if _, err := s.UpdateAction(&action); err != nil {
+			return result, fmt.Errorf("Updating action %s failed: %s", step.name, err)
+		}
+		result.Updated = append(result.Updated, step.name)
+	}
+
+	return result, nil
+}
+
+// exportAction normalizes the action and replaces its secrets by placeholders
+// named after the action and the parameter.
+func exportAction(action Action) (Action, error) {
+
+	m := toJSONMap(action)
+	for field := range actionIgnoredFields {
+		delete(m, field)
+	}
+
+	j, err := json.Marshal(m)
+	if err != nil {
+		return action, err
+	}
+
+	var exported Action
+	if err := json.Unmarshal(j, &exported); err != nil {
+		return action, err
+	}
+
+	if exported.ActionCustomParamSpecs == nil {
+		return exported, nil
+	}
+
+	prefix := secretPrefix + placeholderName(stringValue(exported.Name))
+
+	for i := range *exported.ActionCustomParamSpecs {
+
+		spec := &(*exported.ActionCustomParamSpecs)[i]
+		param := prefix + "_" + placeholderName(stringValue(spec.ParamName))
+
+		if strings.EqualFold(stringValue(spec.Type), "password") && stringValue(spec.DefaultValue) != "" {
+			spec.DefaultValue = String("${" + param + "_DEFAULT}")
+		}
+
+		if ws := spec.WebserviceListParams; ws != nil {
+			if stringValue(ws.Username) != "" {
+				ws.Username = String("${" + param + "_USERNAME}")
+			}
+			if stringValue(ws.Password) != "" {
+				ws.Password = String("${" + param + "_PASSWORD}")
+			}
+		}
+	}
+
+	return exported, nil
+}
+
+// checkSecrets reports every placeholder that neither secrets nor the
+// environment provide.
+func (l *ActionLibrary) checkSecrets(secrets map[string]string) error {
+
+	var missing []string
+
+	for _, name := range l.Placeholders() {
+		if _, ok := lookupSecret(name, secrets); !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New("Missing values for placeholders: " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// resolveActionSecrets replaces the placeholders in every string of the action.
+func resolveActionSecrets(action Action, secrets map[string]string) (Action, error) {
+
+	var resolved Action
+
+	m := toJSONMap(action)
+	resolvePlaceholderValues(m, secrets)
+
+	j, err := json.Marshal(m)
+	if err != nil {
+		return resolved, err
+	}
+
+	err = json.Unmarshal(j, &resolved)
+
+	return resolved, err
+}
+
+func resolvePlaceholderValues(v interface{}, secrets map[string]string) interface{} {
+
+	switch value := v.(type) {
+
+	case string:
+		return secretPlaceholder.ReplaceAllStringFunc(value, func(match string) string {
+			if secret, ok := lookupSecret(match[2:len(match)-1], secrets); ok {
+				return secret
+			}
+			return match
+		})
+
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = resolvePlaceholderValues(item, secrets)
+		}
+
+	case []interface{}:
+		for i, item := range value {
+			value[i] = resolvePlaceholderValues(item, secrets)
+		}
+	}
+
+	return v
+}
+
+func lookupSecret(name string, secrets map[string]string) (string, bool) {
+
+	if secret, ok := secrets[name]; ok {
+		return secret, true
+	}
+
+	return os.LookupEnv(name)
+}
+
+// placeholderName turns a name into an environment variable style name.
+func placeholderName(name string) string {
+
+	var b strings.Builder
+	underscore := false
+
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+
+	placeholder := strings.TrimSuffix(b.String(), "_")
+	if placeholder == "" || placeholder[0] >= '0' && placeholder[0] <= '9' {
+		placeholder = "ACTION_" + placeholder
+	}
+
+	return placeholder
+}
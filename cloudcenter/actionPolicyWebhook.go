@@ -0,0 +1,406 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ActionTypeEmail   = "EMAIL"
+	ActionTypeWebhook = "WEBHOOK"
+)
+
+// Inputs of a webhook action as SimulateActionPolicy reads them. The names and
+// defaults are defined by this library, not taken from CloudCenter, so the
+// actions must be set up with them. Only url is required; the method defaults
+// to POST, the content type to application/json and the body to the event as
+// JSON.
+const (
+	WebhookInputURL         = "url"
+	WebhookInputMethod      = "method"
+	WebhookInputContentType = "contentType"
+	WebhookInputBody        = "body"
+)
+
+// webhookTokenHeader carries the token checked by ActionPolicyWebhook, as an
+// alternative to the token query parameter.
+const webhookTokenHeader = "X-Webhook-Token"
+
+// maxWebhookBody bounds the size of a webhook callback.
+const maxWebhookBody = 1 << 20
+
+// ActionPolicyEvent is an event an action policy reacts to. Params holds the
+// values of the macros, such as jobName or appName, that action inputs refer
+// to as %jobName% or %appName%.
+//
+// The JSON format of the event is defined by this library, not by CloudCenter,
+// which does not document a webhook payload. A webhook action sends it when
+// its body input renders these fields, e.g.
+// {"entityType":"...","eventName":"...","params":{"jobName":"%jobName%"}}.
+type ActionPolicyEvent struct {
+	PolicyId   string            `json:"policyId,omitempty"`
+	PolicyName string            `json:"policyName,omitempty"`
+	EntityType string            `json:"entityType"`
+	EventName  string            `json:"eventName"`
+	Time       int64             `json:"time,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+
+	// Raw is the callback body as received.
+	Raw json.RawMessage `json:"-"`
+}
+
+// ActionPolicyEventHandler handles an event received by ActionPolicyWebhook.
+// An error is reported to CloudCenter as a server error.
+type ActionPolicyEventHandler func(ctx context.Context, event *ActionPolicyEvent) error
+
+// ActionPolicyWebhook is an http.Handler receiving the callbacks of webhook
+// actions and dispatching them to the handlers registered for their entity
+// type and event name.
+type ActionPolicyWebhook struct {
+
+	// Token, when set, must be sent by every callback in the token query
+	// parameter or the X-Webhook-Token header, e.g. by including it in the
+	// url input of the action.
+	Token string
+
+	mu       sync.RWMutex
+	handlers map[actionPolicyEventKey][]ActionPolicyEventHandler
+}
+
+type actionPolicyEventKey struct {
+	entityType string
+	eventName  string
+}
+
+// ActionPolicyRequest is what an action of a policy would send for an event.
+// Inputs are the action's inputs with their macros replaced. For webhook
+// actions, URL, Method, ContentType and Body are filled in from the inputs.
+type ActionPolicyRequest struct {
+	ActionType  string            `json:"actionType"`
+	Inputs      map[string]string `json:"inputs,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Body        string            `json:"body,omitempty"`
+
+	// Unresolved lists the macros the event has no value for. They are left
+	// in place in the inputs.
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+var actionPolicyMacro = regexp.MustCompile(`%([A-Za-z0-9_.]+)%`)
+
+func NewActionPolicyWebhook(token string) *ActionPolicyWebhook {
+	return &ActionPolicyWebhook{
+		Token:    token,
+		handlers: make(map[actionPolicyEventKey][]ActionPolicyEventHandler),
+	}
+}
+
+// Handle registers handler for the events with the given entity type and event
+// name, compared case-insensitively. An empty entity type or event name
+// matches any. Every matching handler is called, in registration order.
+func (w *ActionPolicyWebhook) Handle(entityType string, eventName string, handler ActionPolicyEventHandler) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.handlers == nil {
+		w.handlers = make(map[actionPolicyEventKey][]ActionPolicyEventHandler)
+	}
+
+	key := actionPolicyEventKey{strings.ToLower(entityType), strings.ToLower(eventName)}
+	w.handlers[key] = append(w.handlers[key], handler)
+}
+
+func (w *ActionPolicyWebhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" && r.Method != "PUT" {
+		rw.Header().Set("Allow", "POST, PUT")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if w.Token != "" {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = r.Header.Get(webhookTokenHeader)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(w.Token)) != 1 {
+			http.Error(rw, "invalid token", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := DecodeActionPolicyEvent(body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, handler := range w.handlersFor(event) {
+		if err := handler(r.Context(), event); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (w *ActionPolicyWebhook) handlersFor(event *ActionPolicyEvent) []ActionPolicyEventHandler {
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	entityType := strings.ToLower(event.EntityType)
+	eventName := strings.ToLower(event.EventName)
+
+	var handlers []ActionPolicyEventHandler
+	seen := make(map[actionPolicyEventKey]bool)
+
+	for _, key := range []actionPolicyEventKey{
+		{entityType, eventName},
+		{entityType, ""},
+		{"", eventName},
+		{"", ""},
+	} {
+		if !seen[key] {
+			seen[key] = true
+			handlers = append(handlers, w.handlers[key]...)
+		}
+	}
+
+	return handlers
+}
+
+// DecodeActionPolicyEvent decodes a webhook callback body. The body is a JSON
+// object in the library-defined ActionPolicyEvent format, or any other object
+// rendered from the action's body input. Top-level fields other than those of
+// ActionPolicyEvent are added to Params.
+func DecodeActionPolicyEvent(body []byte) (*ActionPolicyEvent, error) {
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.New("Action policy event is not a JSON object: " + err.Error())
+	}
+
+	event := &ActionPolicyEvent{
+		Params: make(map[string]string),
+		Raw:    json.RawMessage(append([]byte{}, body...)),
+	}
+
+	for key, value := range fields {
+
+		switch key {
+		case "policyId":
+			event.PolicyId = webhookString(value)
+		case "policyName":
+			event.PolicyName = webhookString(value)
+		case "entityType":
+			event.EntityType = webhookString(value)
+		case "eventName":
+			event.EventName = webhookString(value)
+		case "time":
+			t, err := strconv.ParseFloat(webhookString(value), 64)
+			if err != nil {
+				return nil, errors.New("Action policy event time is not a number")
+			}
+			event.Time = int64(t)
+		case "params":
+			params, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("Action policy event params is not an object")
+			}
+			for name, param := range params {
+				event.Params[name] = webhookString(param)
+			}
+		default:
+			if _, isObject := value.(map[string]interface{}); !isObject {
+				if _, isArray := value.([]interface{}); !isArray {
+					event.Params[key] = webhookString(value)
+				}
+			}
+		}
+	}
+
+	return event, nil
+}
+
+// SimulateActionPolicy renders what each action of the policy would send for
+// the event, without calling CloudCenter. Macros are replaced by the event's
+// Params and by policyId, policyName, entityType and eventName. The event must
+// match the policy's entity type and event name; a disabled policy sends
+// nothing. Webhook actions are read with the library-defined WebhookInput
+// names.
+func SimulateActionPolicy(policy *ActionPolicy, event *ActionPolicyEvent) ([]ActionPolicyRequest, error) {
+
+	if !strings.EqualFold(stringValue(policy.EntityType), event.EntityType) || !strings.EqualFold(stringValue(policy.EventName), event.EventName) {
+		return nil, fmt.Errorf("Action policy %s reacts to %s %s, not %s %s",
+			stringValue(policy.Name), stringValue(policy.EntityType), stringValue(policy.EventName), event.EntityType, event.EventName)
+	}
+
+	if policy.Enabled != nil && !*policy.Enabled {
+		return []ActionPolicyRequest{}, nil
+	}
+
+	sent := *event
+	if sent.PolicyId == "" {
+		sent.PolicyId = stringValue(policy.Id)
+	}
+	if sent.PolicyName == "" {
+		sent.PolicyName = stringValue(policy.Name)
+	}
+
+	macros := map[string]string{
+		"policyId":   sent.PolicyId,
+		"policyName": sent.PolicyName,
+		"entityType": sent.EntityType,
+		"eventName":  sent.EventName,
+	}
+	for name, value := range sent.Params {
+		macros[name] = value
+	}
+
+	requests := []ActionPolicyRequest{}
+
+	if policy.Actions == nil {
+		return requests, nil
+	}
+
+	for i, action := range *policy.Actions {
+
+		request := ActionPolicyRequest{
+			ActionType: strings.ToUpper(stringValue(action.ActionType)),
+			Inputs:     make(map[string]string),
+		}
+
+		unresolved := make(map[string]bool)
+
+		if action.ActionInputs != nil {
+			for _, input := range *action.ActionInputs {
+				request.Inputs[stringValue(input.Name)] = expandActionMacros(stringValue(input.Value), macros, unresolved)
+			}
+		}
+
+		for macro := range unresolved {
+			request.Unresolved = append(request.Unresolved, macro)
+		}
+		sort.Strings(request.Unresolved)
+
+		if request.ActionType == ActionTypeWebhook {
+
+			request.URL = request.Inputs[WebhookInputURL]
+			if request.URL == "" {
+				return nil, fmt.Errorf("Action %d of action policy %s has no %s input", i+1, stringValue(policy.Name), WebhookInputURL)
+			}
+
+			request.Method = strings.ToUpper(request.Inputs[WebhookInputMethod])
+			if request.Method == "" {
+				request.Method = "POST"
+			}
+
+			request.ContentType = request.Inputs[WebhookInputContentType]
+			if request.ContentType == "" {
+				request.ContentType = "application/json"
+			}
+
+			if body, ok := request.Inputs[WebhookInputBody]; ok && body != "" {
+				request.Body = body
+			} else {
+				j, err := json.Marshal(sent)
+				if err != nil {
+					return nil, err
+				}
+				request.Body = string(j)
+			}
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// NewRequest builds the HTTP request of a rendered webhook action, e.g. to
+// deliver a simulated event to an ActionPolicyWebhook.
+func (r *ActionPolicyRequest) NewRequest(ctx context.Context) (*http.Request, error) {
+
+	if r.ActionType != ActionTypeWebhook {
+		return nil, errors.New("Action type " + r.ActionType + " does not send an HTTP request")
+	}
+
+	req, err := http.NewRequest(r.Method, r.URL, strings.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", r.ContentType)
+
+	return req.WithContext(ctx), nil
+}
+
+// expandActionMacros replaces the %name% macros of value, recording those
+// without a value in unresolved.
+func expandActionMacros(value string, macros map[string]string, unresolved map[string]bool) string {
+
+	return actionPolicyMacro.ReplaceAllStringFunc(value, func(match string) string {
+		name := match[1 : len(match)-1]
+		if v, ok := macros[name]; ok {
+			return v
+		}
+		unresolved[name] = true
+		return match
+	})
+}
+
+func webhookString(v interface{}) string {
+
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+
+	j, _ := json.Marshal(v)
+
+	return string(j)
+}
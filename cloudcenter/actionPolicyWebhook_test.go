@@ -0,0 +1,261 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeActionPolicyEvent(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		body    string
+		want    ActionPolicyEvent
+		wantErr bool
+	}{
+		{
+			name: "library format",
+			body: `{"policyId":"3","policyName":"notify","entityType":"Application Deployment","eventName":"deploy_failed","time":1760000000000,"params":{"jobName":"web","nodes":2}}`,
+			want: ActionPolicyEvent{
+				PolicyId:   "3",
+				PolicyName: "notify",
+				EntityType: "Application Deployment",
+				EventName:  "deploy_failed",
+				Time:       1760000000000,
+				Params:     map[string]string{"jobName": "web", "nodes": "2"},
+			},
+		},
+		{
+			name: "custom body fields become params",
+			body: `{"entityType":"VM","eventName":"vm_stopped","vmName":"web-1","running":false,"tags":["a"],"extra":{"a":1}}`,
+			want: ActionPolicyEvent{
+				EntityType: "VM",
+				EventName:  "vm_stopped",
+				Params:     map[string]string{"vmName": "web-1", "running": "false"},
+			},
+		},
+		{
+			name: "time as string",
+			body: `{"time":"1760000000000"}`,
+			want: ActionPolicyEvent{Time: 1760000000000, Params: map[string]string{}},
+		},
+		{name: "not an object", body: `["a"]`, wantErr: true},
+		{name: "invalid json", body: `{`, wantErr: true},
+		{name: "time not a number", body: `{"time":"soon"}`, wantErr: true},
+		{name: "params not an object", body: `{"params":"jobName"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			event, err := DecodeActionPolicyEvent([]byte(test.body))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("DecodeActionPolicyEvent succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(event.Raw) != test.body {
+				t.Errorf("Raw = %s, want the body", event.Raw)
+			}
+
+			event.Raw = nil
+			if !reflect.DeepEqual(*event, test.want) {
+				t.Errorf("event = %+v, want %+v", *event, test.want)
+			}
+		})
+	}
+}
+
+func testActionPolicy(actions ...Actions) *ActionPolicy {
+	return &ActionPolicy{
+		Id:         String("3"),
+		Name:       String("notify"),
+		EntityType: String("Application Deployment"),
+		EventName:  String("deploy_failed"),
+		Enabled:    Bool(true),
+		Actions:    &actions,
+	}
+}
+
+func testAction(actionType string, inputs ...string) Actions {
+
+	var actionInputs []ActionInput
+	for i := 0; i+1 < len(inputs); i += 2 {
+		actionInputs = append(actionInputs, ActionInput{Name: String(inputs[i]), Value: String(inputs[i+1])})
+	}
+
+	return Actions{ActionType: String(actionType), ActionInputs: &actionInputs}
+}
+
+func TestSimulateActionPolicy(t *testing.T) {
+
+	event := &ActionPolicyEvent{
+		EntityType: "application deployment",
+		EventName:  "DEPLOY_FAILED",
+		Params:     map[string]string{"jobName": "web"},
+	}
+
+	disabled := testActionPolicy(testAction(ActionTypeWebhook, "url", "http://hooks.example.com"))
+	disabled.Enabled = Bool(false)
+
+	tests := []struct {
+		name    string
+		policy  *ActionPolicy
+		want    []ActionPolicyRequest
+		wantErr bool
+	}{
+		{
+			name:   "webhook defaults",
+			policy: testActionPolicy(testAction("webhook", "url", "http://hooks.example.com/%policyName%")),
+			want: []ActionPolicyRequest{{
+				ActionType:  ActionTypeWebhook,
+				Inputs:      map[string]string{"url": "http://hooks.example.com/notify"},
+				URL:         "http://hooks.example.com/notify",
+				Method:      "POST",
+				ContentType: "application/json",
+				Body:        `{"policyId":"3","policyName":"notify","entityType":"application deployment","eventName":"DEPLOY_FAILED","params":{"jobName":"web"}}`,
+			}},
+		},
+		{
+			name: "webhook inputs and unresolved macros",
+			policy: testActionPolicy(testAction(ActionTypeWebhook,
+				"url", "http://hooks.example.com",
+				"method", "put",
+				"contentType", "text/plain",
+				"body", "%jobName% failed in %cloud% %region%")),
+			want: []ActionPolicyRequest{{
+				ActionType: ActionTypeWebhook,
+				Inputs: map[string]string{
+					"url":         "http://hooks.example.com",
+					"method":      "put",
+					"contentType": "text/plain",
+					"body":        "web failed in %cloud% %region%",
+				},
+				URL:         "http://hooks.example.com",
+				Method:      "PUT",
+				ContentType: "text/plain",
+				Body:        "web failed in %cloud% %region%",
+				Unresolved:  []string{"cloud", "region"},
+			}},
+		},
+		{
+			name:   "email",
+			policy: testActionPolicy(testAction(ActionTypeEmail, "subject", "%eventName% for %jobName%")),
+			want: []ActionPolicyRequest{{
+				ActionType: ActionTypeEmail,
+				Inputs:     map[string]string{"subject": "DEPLOY_FAILED for web"},
+			}},
+		},
+		{
+			name:   "disabled",
+			policy: disabled,
+			want:   []ActionPolicyRequest{},
+		},
+		{
+			name:    "webhook without url",
+			policy:  testActionPolicy(testAction(ActionTypeWebhook, "method", "POST")),
+			wantErr: true,
+		},
+		{
+			name: "other event",
+			policy: func() *ActionPolicy {
+				policy := testActionPolicy()
+				policy.EventName = String("deploy_succeeded")
+				return policy
+			}(),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			requests, err := SimulateActionPolicy(test.policy, event)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("SimulateActionPolicy succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(requests, test.want) {
+				t.Errorf("requests = %+v, want %+v", requests, test.want)
+			}
+		})
+	}
+}
+
+func TestActionPolicyWebhook(t *testing.T) {
+
+	body := `{"entityType":"VM","eventName":"vm_stopped"}`
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		handlerErr error
+		wantStatus int
+		wantCalls  int
+	}{
+		{"token in query", "/?token=secret", body, nil, http.StatusNoContent, 2},
+		{"missing token", "/", body, nil, http.StatusUnauthorized, 0},
+		{"wrong token", "/?token=guess", body, nil, http.StatusUnauthorized, 0},
+		{"invalid body", "/?token=secret", `[]`, nil, http.StatusBadRequest, 0},
+		{"handler error", "/?token=secret", body, errors.New("failed"), http.StatusInternalServerError, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			calls := 0
+			handler := func(ctx context.Context, event *ActionPolicyEvent) error {
+				calls++
+				return test.handlerErr
+			}
+
+			webhook := NewActionPolicyWebhook("secret")
+			webhook.Handle("vm", "VM_STOPPED", handler)
+			webhook.Handle("", "", handler)
+			webhook.Handle("VM", "vm_started", handler)
+
+			rec := httptest.NewRecorder()
+			webhook.ServeHTTP(rec, httptest.NewRequest("POST", test.target, strings.NewReader(test.body)))
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, test.wantStatus)
+			}
+			if calls != test.wantCalls {
+				t.Errorf("handlers called %d times, want %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
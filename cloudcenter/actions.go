@@ -153,6 +153,38 @@ func (s *Client) GetActions() ([]Action, error) {
 	return actions, nil
 }
 
+// getAllActions pages through the actions, as GetActions only returns the
+// first page.
+func (s *Client) getAllActions() ([]Action, error) {
+
+	var actions []Action
+
+	for page := 0; ; page++ {
+
+		url := fmt.Sprintf(s.BaseURL + "/v1/actions?page=" + strconv.Itoa(page))
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var data ActionAPIResponse
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, data.ActionJaxbs...)
+
+		if len(data.ActionJaxbs) == 0 || data.TotalPages == nil || int64(page+1) >= *data.TotalPages {
+			return actions, nil
+		}
+	}
+}
+
 func (s *Client) GetAction(id int) (*Action, error) {
 
 	var data Action
@@ -0,0 +1,319 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	AgingPolicyTime = "TIME"
+	AgingPolicyCost = "COST"
+)
+
+// AgingProjection is where a resource stands against an aging policy. Time
+// amounts are durations and cost amounts are in the policy's currency; which
+// of the two apply depends on the units of the policy's limits.
+type AgingProjection struct {
+	PolicyId     string `json:"policyId"`
+	PolicyName   string `json:"policyName"`
+	PolicyType   string `json:"policyType"`
+	ResourceId   string `json:"resourceId"`
+	ResourceType string `json:"resourceType"`
+
+	// ExpiresAt is the policy's estimated end time for the resource, or a
+	// projection from its start time and, for cost policies, its spend so far.
+	// It is zero when it cannot be determined.
+	ExpiresAt     time.Time     `json:"expiresAt,omitempty"`
+	TimeRemaining time.Duration `json:"timeRemaining"`
+
+	AccruedCost     float64 `json:"accruedCost,omitempty"`
+	AllowedCost     float64 `json:"allowedCost,omitempty"`
+	BudgetRemaining float64 `json:"budgetRemaining,omitempty"`
+
+	// GraceTime and GraceBudget are the headroom of the grace period after
+	// expiry, when the policy allows one.
+	GraceTime   time.Duration `json:"graceTime,omitempty"`
+	GraceBudget float64       `json:"graceBudget,omitempty"`
+
+	// ExtensionTime and ExtensionBudget are what the extensions not used yet
+	// could add, when the policy allows extensions.
+	ExtensionsRemaining int64         `json:"extensionsRemaining"`
+	ExtensionTime       time.Duration `json:"extensionTime,omitempty"`
+	ExtensionBudget     float64       `json:"extensionBudget,omitempty"`
+
+	ApprovalPending bool `json:"approvalPending,omitempty"`
+	Expired         bool `json:"expired"`
+	Due             bool `json:"due"`
+}
+
+type AgingProjections []AgingProjection
+
+// GetAgingProjections fetches every aging policy with its resources and
+// projects them with ProjectAgingPolicies.
+func (s *Client) GetAgingProjections(ctx context.Context, dueWithin time.Duration) (AgingProjections, error) {
+
+	policies, err := s.GetAgingPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	err = forEachConcurrently(ctx, len(policies), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		policyId, err := strconv.Atoi(stringValue(policies[i].Id))
+		if err != nil {
+			return err
+		}
+
+		policy, err := s.GetAgingPolicy(policyId)
+		if err != nil {
+			return err
+		}
+
+		policies[i] = *policy
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ProjectAgingPolicies(policies, time.Now(), dueWithin), nil
+}
+
+// ProjectAgingPolicies computes a projection for every resource of every
+// enabled policy at time now. Resources expiring within dueWithin, or already
+// expired, are flagged Due. The result is sorted by urgency: earliest expiry
+// first and unknown expiries last.
+func ProjectAgingPolicies(policies []AgingPolicy, now time.Time, dueWithin time.Duration) AgingProjections {
+
+	var projections AgingProjections
+
+	for _, policy := range policies {
+
+		if policy.Enabled != nil && !*policy.Enabled {
+			continue
+		}
+
+		if policy.Resources == nil {
+			continue
+		}
+
+		for _, resource := range *policy.Resources {
+			projections = append(projections, projectAgingResource(policy, resource, now, dueWithin))
+		}
+	}
+
+	sort.SliceStable(projections, func(i, j int) bool {
+		a, b := projections[i].ExpiresAt, projections[j].ExpiresAt
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+
+	return projections
+}
+
+func projectAgingResource(policy AgingPolicy, resource AgingPolicyResource, now time.Time, dueWithin time.Duration) AgingProjection {
+
+	p := AgingProjection{
+		PolicyId:        stringValue(policy.Id),
+		PolicyName:      stringValue(policy.Name),
+		PolicyType:      strings.ToUpper(stringValue(policy.Type)),
+		ResourceId:      stringValue(resource.ResourceId),
+		ResourceType:    stringValue(resource.ResourceType),
+		ApprovalPending: resource.IsApprovalPending != nil && *resource.IsApprovalPending,
+	}
+
+	if resource.AccruedCost != nil {
+		p.AccruedCost = *resource.AccruedCost
+	}
+	if resource.AllowedCost != nil {
+		p.AllowedCost = *resource.AllowedCost
+	} else if p.PolicyType == AgingPolicyCost && policy.Limit != nil && policy.Limit.Amount != nil {
+		p.AllowedCost = *policy.Limit.Amount
+	}
+	if p.PolicyType == AgingPolicyCost {
+		p.BudgetRemaining = p.AllowedCost - p.AccruedCost
+	}
+
+	var start time.Time
+	if resource.ResourceStartTime != nil {
+		start = epochMillis(*resource.ResourceStartTime)
+	} else if resource.AppliedDate != nil {
+		start = epochMillis(*resource.AppliedDate)
+	}
+
+	switch {
+	case resource.EstimatedPolicyEndTime != nil && *resource.EstimatedPolicyEndTime > 0:
+		p.ExpiresAt = epochMillis(*resource.EstimatedPolicyEndTime)
+
+	case p.PolicyType == AgingPolicyCost:
+		// extrapolate the spend so far to when the budget runs out
+		elapsed := now.Sub(start)
+		if !start.IsZero() && elapsed > 0 && p.AccruedCost > 0 {
+			rate := p.AccruedCost / elapsed.Hours()
+			p.ExpiresAt = now.Add(time.Duration(p.BudgetRemaining / rate * float64(time.Hour)))
+		}
+
+	case policy.Limit != nil && !start.IsZero():
+		if limit, ok := agingDuration(policy.Limit.Amount, policy.Limit.Unit); ok {
+			p.ExpiresAt = start.Add(limit)
+		}
+	}
+
+	if policy.AllowGracePeriodForTermination != nil && *policy.AllowGracePeriodForTermination && policy.GraceLimit != nil {
+		if grace, ok := agingDuration(policy.GraceLimit.Amount, policy.GraceLimit.Unit); ok {
+			p.GraceTime = grace
+		} else if policy.GraceLimit.Amount != nil {
+			p.GraceBudget = *policy.GraceLimit.Amount
+		}
+	}
+
+	if policy.AllowPolicyExtension != nil && *policy.AllowPolicyExtension && policy.ExtensionLimit != nil {
+
+		if policy.ExtensionLimit.NumOfExtensions != nil {
+			p.ExtensionsRemaining = *policy.ExtensionLimit.NumOfExtensions
+			if resource.NumberOfExtensionsUsed != nil {
+				p.ExtensionsRemaining -= *resource.NumberOfExtensionsUsed
+			}
+			if p.ExtensionsRemaining < 0 {
+				p.ExtensionsRemaining = 0
+			}
+		}
+
+		if each := policy.ExtensionLimit.LimitOfEachExtension; each != nil {
+			if extension, ok := agingDuration(each.Amount, each.Unit); ok {
+				p.ExtensionTime = time.Duration(p.ExtensionsRemaining) * extension
+			} else if each.Amount != nil {
+				p.ExtensionBudget = float64(p.ExtensionsRemaining) * *each.Amount
+			}
+		}
+	}
+
+	if !p.ExpiresAt.IsZero() {
+		p.TimeRemaining = p.ExpiresAt.Sub(now)
+		p.Expired = p.TimeRemaining <= 0
+		p.Due = p.TimeRemaining <= dueWithin
+	}
+
+	if p.PolicyType == AgingPolicyCost && p.AllowedCost > 0 && p.BudgetRemaining <= 0 {
+		p.Expired = true
+		p.Due = true
+	}
+
+	return p
+}
+
+// Due returns the projections flagged Due, keeping their order.
+func (p AgingProjections) Due() AgingProjections {
+
+	var due AgingProjections
+
+	for _, projection := range p {
+		if projection.Due {
+			due = append(due, projection)
+		}
+	}
+
+	return due
+}
+
+func (p AgingProjections) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, p)
+}
+
+// WriteText writes the projections as a table, flagging due resources with "!".
+func (p AgingProjections) WriteText(w io.Writer) error {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "\tPOLICY\tRESOURCE\tEXPIRES\tREMAINING\tBUDGET LEFT\tGRACE\tEXTENSIONS")
+
+	for _, projection := range p {
+
+		flag := ""
+		if projection.Due {
+			flag = "!"
+		}
+
+		expires, remaining := "-", "-"
+		if !projection.ExpiresAt.IsZero() {
+			expires = projection.ExpiresAt.Format(time.RFC3339)
+			remaining = projection.TimeRemaining.Round(time.Minute).String()
+		}
+
+		budget := "-"
+		if projection.PolicyType == AgingPolicyCost {
+			budget = strconv.FormatFloat(projection.BudgetRemaining, 'f', 2, 64)
+		}
+
+		grace := "-"
+		if projection.GraceTime > 0 {
+			grace = projection.GraceTime.String()
+		} else if projection.GraceBudget > 0 {
+			grace = strconv.FormatFloat(projection.GraceBudget, 'f', 2, 64)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s %s\t%s\t%s\t%s\t%s\t%d\n",
+			flag,
+			projection.PolicyName,
+			projection.ResourceType,
+			projection.ResourceId,
+			expires,
+			remaining,
+			budget,
+			grace,
+			projection.ExtensionsRemaining)
+	}
+
+	return tw.Flush()
+}
+
+// agingDuration converts a policy amount in a time unit (minutes, hours, days,
+// weeks, months or years) into a duration. It returns false for other units,
+// such as currencies.
+func agingDuration(amount *float64, unit *string) (time.Duration, bool) {
+
+	if amount == nil || unit == nil {
+		return 0, false
+	}
+
+	units := map[string]time.Duration{
+		"MINUTE": time.Minute,
+		"HOUR":   time.Hour,
+		"DAY":    24 * time.Hour,
+		"WEEK":   7 * 24 * time.Hour,
+		"MONTH":  30 * 24 * time.Hour,
+		"YEAR":   365 * 24 * time.Hour,
+	}
+
+	size, ok := units[strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(*unit)), "S")]
+	if !ok {
+		return 0, false
+	}
+
+	return time.Duration(*amount * float64(size)), true
+}
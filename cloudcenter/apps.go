@@ -18,10 +18,15 @@ package cloudcenter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	neturl "net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 
 	validator "gopkg.in/validator.v2"
@@ -202,18 +207,146 @@ func (s *Client) GetApp(appId int) (*App, error) {
 	return app, nil
 }
 
-func (s *Client) ImportApp(filename string) error {
+// Import modes of ImportApp.
+const (
+	// AppImportNew creates a new application and fails when one with the same
+	// name exists.
+	AppImportNew = ""
+
+	// AppImportOverwrite replaces the existing application with the same name.
+	AppImportOverwrite = "OVERWRITE"
+
+	// AppImportNewVersion adds the imported profile as a new version of the
+	// existing application with the same name.
+	AppImportNewVersion = "NEW_VERSION"
+)
+
+// AppImportOptions controls ImportApp.
+type AppImportOptions struct {
+
+	// Filename is the name the archive is uploaded under. It defaults to
+	// app.zip.
+	Filename string
+
+	// Mode is one of AppImportNew, AppImportOverwrite and AppImportNewVersion.
+	Mode string
+
+	// Version is the version of the application to create with
+	// AppImportNewVersion. CloudCenter picks the next version when empty.
+	Version string
+}
+
+// ExportApp returns the archive of the given version of the application, as
+// accepted by ImportApp. The caller must close it.
+func (s *Client) ExportApp(ctx context.Context, appId int, version string) (io.ReadCloser, error) {
+
+	url := fmt.Sprintf(s.BaseURL + "/v1/apps/portation?appId=" + strconv.Itoa(appId))
+	if version != "" {
+		url += "&version=" + neturl.QueryEscape(version)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.send(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// ImportApp uploads the application archive read from r and returns the id,
+// name and version of the imported application. The mode is sent with the
+// archive as the overwrite or newVersion form field, and the version as the
+// version field. An unknown mode, or a version without AppImportNewVersion, is
+// rejected before anything is sent.
+func (s *Client) ImportApp(ctx context.Context, r io.Reader, opts AppImportOptions) (*App, error) {
+
+	filename := opts.Filename
+	if filename == "" {
+		filename = "app.zip"
+	}
+
+	fields := make(map[string]string)
+
+	switch opts.Mode {
+	case AppImportNew:
+	case AppImportOverwrite:
+		fields["overwrite"] = "true"
+	case AppImportNewVersion:
+		fields["newVersion"] = "true"
+		if opts.Version != "" {
+			fields["version"] = opts.Version
+		}
+	default:
+		return nil, errors.New("Unknown app import mode " + opts.Mode)
+	}
+
+	if opts.Version != "" && opts.Mode != AppImportNewVersion {
+		return nil, errors.New("AppImportOptions.Version requires the AppImportNewVersion mode")
+	}
 
 	url := fmt.Sprintf(s.BaseURL + "/v1/apps/portation")
 
-	body, err := s.sendFile(filename, url)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println(string(body))
+	body, err := s.sendFile(req.WithContext(ctx), filename, r, fields)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return decodeImportedApp(body)
+}
+
+// ImportAppFile is ImportApp for an archive on disk, uploaded under its base
+// name unless opts.Filename is set.
+func (s *Client) ImportAppFile(ctx context.Context, filename string, opts AppImportOptions) (*App, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if opts.Filename == "" {
+		opts.Filename = filepath.Base(filename)
+	}
+
+	return s.ImportApp(ctx, f, opts)
+}
+
+// decodeImportedApp reads the imported application from the response, which
+// is either the application itself or a list of applications.
+func decodeImportedApp(body []byte) (*App, error) {
+
+	var apps []App
+
+	var data AppAPIResponse
+	if err := json.Unmarshal(body, &data); err == nil && len(data.Apps) > 0 {
+		apps = data.Apps
+	} else if err := json.Unmarshal(body, &apps); err != nil {
+		var app App
+		if err := json.Unmarshal(body, &app); err != nil {
+			return nil, errors.New("Unexpected app import response: " + string(body))
+		}
+		apps = []App{app}
+	}
+
+	if len(apps) == 0 || nonzero(apps[0].Id) {
+		return nil, errors.New("App import response has no application: " + string(body))
+	}
+
+	return &App{
+		Id:      apps[0].Id,
+		Name:    apps[0].Name,
+		Version: apps[0].Version,
+	}, nil
 }
 
 func (s *Client) UpdateApp(app *App) error {
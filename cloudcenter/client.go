@@ -109,13 +109,11 @@ package cloudcenter
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"reflect"
 )
 
@@ -145,6 +143,28 @@ func NewClient(username, password, baseURL string, useSSH bool, serverCRTPath st
 
 func (s *Client) doRequest(req *http.Request) ([]byte, error) {
 
+	if !s.useSSH {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := s.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// send performs the request with the client's credentials. The response is
+// returned unread when its status is a success; otherwise its body is
+// returned as the error.
+func (s *Client) send(req *http.Request) (*http.Response, error) {
+
 	var client *http.Client
 
 	if s.useSSH {
@@ -170,7 +190,6 @@ func (s *Client) doRequest(req *http.Request) ([]byte, error) {
 		client = &http.Client{Transport: transport}
 
 	} else {
-		req.Header.Add("Content-Type", "application/json")
 		req.SetBasicAuth(s.Username, s.Password)
 		tr := &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
@@ -182,66 +201,77 @@ func (s *Client) doRequest(req *http.Request) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 
 	if 200 != resp.StatusCode && 201 != resp.StatusCode && 202 != resp.StatusCode && 204 != resp.StatusCode {
-		return nil, fmt.Errorf("%s", body)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: body}
 	}
 
-	return body, nil
+	return resp, nil
 }
 
-func (s *Client) sendFile(filename string, url string) ([]byte, error) {
-	r, w := io.Pipe()
-	writer := multipart.NewWriter(w)
+// statusError is returned for unsuccessful responses. Its message is the
+// response body.
+type statusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *statusError) Error() string {
+	return string(e.Body)
+}
+
+// isNotFound reports whether err is a 404 response.
+func isNotFound(err error) bool {
+
+	statusErr, ok := err.(*statusError)
+
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
+
+// sendFile uploads the content of r as the multipart form file filename,
+// along with the given form fields.
+func (s *Client) sendFile(req *http.Request, filename string, r io.Reader, fields map[string]string) ([]byte, error) {
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
 	go func() {
-		part, err := writer.CreateFormFile("file", filename)
-		if err != nil {
-			w.CloseWithError(err)
-			return
+		for name, value := range fields {
+			if err := writer.WriteField(name, value); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
 		}
-		_, err = io.Copy(part, os.Stdin)
+		part, err := writer.CreateFormFile("file", filename)
 		if err != nil {
-			w.CloseWithError(err)
+			pw.CloseWithError(err)
 			return
 		}
-		err = writer.Close()
+		_, err = io.Copy(part, r)
 		if err != nil {
-			w.CloseWithError(err)
+			pw.CloseWithError(err)
 			return
 		}
+		pw.CloseWithError(writer.Close())
 	}()
 
-	req, err := http.NewRequest("POST", url, r)
-	if err != nil {
-		return nil, err
-	}
+	req.Body = pr
+	req.ContentLength = -1
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	req.SetBasicAuth(s.Username, s.Password)
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Do(req)
+	resp, err := s.send(req)
 	if err != nil {
+		pr.Close()
 		return nil, err
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 
-	if 200 != resp.StatusCode && 201 != resp.StatusCode && 202 != resp.StatusCode && 204 != resp.StatusCode {
-		return nil, fmt.Errorf("%s", body)
-	}
-
-	return body, nil
+	return ioutil.ReadAll(resp.Body)
 }
 
 // Helper routine used to return pointer - will used to simplify the use of the clientlibrary
@@ -274,6 +304,14 @@ func Float64(value float64) *float64 {
 	return &value
 }
 
+// Helper routine used to dereference a string pointer, returning "" for nil
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
 //modified from unexported nonzero function in the validtor package
 //https://github.com/go-validator/validator/blob/v2/builtins.go
 func nonzero(v interface{}) bool {
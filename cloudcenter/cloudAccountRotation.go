@@ -0,0 +1,416 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CloudAccountRef identifies a cloud account by Id or, when Id is zero, by
+// DisplayName.
+type CloudAccountRef struct {
+	TenantId    int    `json:"tenantId"`
+	CloudId     int    `json:"cloudId"`
+	Id          int    `json:"id,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+func (r CloudAccountRef) String() string {
+
+	if r.Id != 0 {
+		return fmt.Sprintf("tenant %d cloud %d account %d", r.TenantId, r.CloudId, r.Id)
+	}
+
+	return fmt.Sprintf("tenant %d cloud %d account %s", r.TenantId, r.CloudId, r.DisplayName)
+}
+
+// CloudAccountCredentials are the credentials of a cloud account. Empty
+// fields keep their current value, and AccountProperties only replaces the
+// properties it names. The API never returns AccountPassword and masks secret
+// properties, so AccountPassword and the values of masked properties are
+// always required.
+type CloudAccountCredentials struct {
+	AccountId         string            `json:"accountId,omitempty"`
+	AccountPassword   string            `json:"accountPassword,omitempty"`
+	AccountProperties map[string]string `json:"accountProperties,omitempty"`
+}
+
+// CloudAccountRotation is one entry of a bulk rotation file. Previous holds
+// the credentials restored if the rotation fails after the update.
+type CloudAccountRotation struct {
+	Account     CloudAccountRef         `json:"account"`
+	Credentials CloudAccountCredentials `json:"credentials"`
+	Previous    CloudAccountCredentials `json:"previous"`
+}
+
+type CloudAccountRotationOptions struct {
+
+	// ContinueOnError rotates the remaining accounts after a failure instead
+	// of stopping.
+	ContinueOnError bool
+
+	// Output, when set, receives one line per account.
+	Output io.Writer
+}
+
+// CloudAccountRotationError is returned when a rotation fails. Updated is set
+// when the failure happened after the update was submitted; the previous
+// credentials were then restored if RolledBack is set, while RollbackErr is
+// set when restoring them failed as well and the account is in a partial
+// state.
+type CloudAccountRotationError struct {
+	Account     CloudAccountRef
+	Err         error
+	Updated     bool
+	RolledBack  bool
+	RollbackErr error
+}
+
+func (e *CloudAccountRotationError) Error() string {
+
+	switch {
+	case !e.Updated:
+		return fmt.Sprintf("Rotating credentials of %s failed, the account was not changed: %s", e.Account, e.Err)
+	case e.RolledBack:
+		return fmt.Sprintf("Rotating credentials of %s failed and was rolled back: %s", e.Account, e.Err)
+	}
+
+	return fmt.Sprintf("Rotating credentials of %s failed: %s; rolling back also failed, the account is in a partial state: %s", e.Account, e.Err, e.RollbackErr)
+}
+
+type CloudAccountRotationResult struct {
+	Rotation   CloudAccountRotation `json:"rotation"`
+	Account    *CloudAccount        `json:"account,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	RolledBack bool                 `json:"rolledBack,omitempty"`
+}
+
+// RotateCloudAccountCredentials validates the new credentials against the
+// cloud, updates the account, waits for the update operation, checks that the
+// account reads back with the new credentials and validates it again. Nothing
+// is changed when the first validation fails. A failure after the update
+// restores previousCreds; the API does not return the current secrets, so the
+// caller has to provide them and they are checked before anything is sent.
+func (s *Client) RotateCloudAccountCredentials(ctx context.Context, ref CloudAccountRef, newCreds CloudAccountCredentials, previousCreds CloudAccountCredentials) (*CloudAccount, error) {
+
+	previous, err := s.getCloudAccountByRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := credentialedCloudAccount(previous, newCreds)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback, err := credentialedCloudAccount(previous, previousCreds)
+	if err != nil {
+		return nil, errors.New("Previous credentials: " + err.Error())
+	}
+
+	err = s.ValidateCloudAccountWithContext(ctx, rotated)
+	if err != nil {
+		return nil, &CloudAccountRotationError{Account: ref, Err: err}
+	}
+
+	account, err := s.UpdateCloudAccountSyncWithContext(ctx, rotated)
+	if err == nil {
+		err = verifyCloudAccountCredentials(account, newCreds)
+	}
+	if err == nil {
+		err = s.ValidateCloudAccountWithContext(ctx, rotated)
+	}
+
+	if err != nil {
+
+		rotationErr := &CloudAccountRotationError{Account: ref, Err: err, Updated: true}
+
+		// the rollback must not be interrupted by a cancelled ctx
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+		defer cancel()
+
+		_, rotationErr.RollbackErr = s.UpdateCloudAccountSyncWithContext(rollbackCtx, rollback)
+		rotationErr.RolledBack = rotationErr.RollbackErr == nil
+
+		return nil, rotationErr
+	}
+
+	return account, nil
+}
+
+// LoadCloudAccountRotations reads a bulk rotation file, a JSON or YAML (.yaml,
+// .yml) list of accounts and their new credentials.
+func LoadCloudAccountRotations(filename string) ([]CloudAccountRotation, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var rotations []CloudAccountRotation
+
+	err = unmarshalByExtension(filename, b, &rotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return rotations, nil
+}
+
+// RotateCloudAccountCredentialsBulk rotates the accounts one after the other
+// with RotateCloudAccountCredentials. It stops at the first failure unless
+// opts.ContinueOnError is set; the results cover every account attempted.
+func (s *Client) RotateCloudAccountCredentialsBulk(ctx context.Context, rotations []CloudAccountRotation, opts CloudAccountRotationOptions) ([]CloudAccountRotationResult, error) {
+
+	var results []CloudAccountRotationResult
+	failed := 0
+
+	for _, rotation := range rotations {
+
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := CloudAccountRotationResult{Rotation: rotation}
+
+		account, err := s.RotateCloudAccountCredentials(ctx, rotation.Account, rotation.Credentials, rotation.Previous)
+		if err != nil {
+			failed++
+			result.Error = err.Error()
+			if rotationErr, ok := err.(*CloudAccountRotationError); ok {
+				result.RolledBack = rotationErr.RolledBack
+			}
+		} else {
+			result.Account = account
+		}
+
+		results = append(results, result)
+
+		if opts.Output != nil {
+			if err != nil {
+				fmt.Fprintf(opts.Output, "FAILED  %s: %s\n", rotation.Account, err)
+			} else {
+				fmt.Fprintf(opts.Output, "ROTATED %s\n", rotation.Account)
+			}
+		}
+
+		if err != nil && !opts.ContinueOnError {
+			break
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d cloud account rotations failed", failed, len(results))
+	}
+
+	return results, nil
+}
+
+// getCloudAccountByRef fetches the referenced account with its TenantId and
+// CloudId set, so it can be passed straight to an update.
+func (s *Client) getCloudAccountByRef(ref CloudAccountRef) (*CloudAccount, error) {
+
+	account, err := s.findCloudAccountByRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonzero(account.TenantId) {
+		account.TenantId = String(strconv.Itoa(ref.TenantId))
+	}
+	if nonzero(account.CloudId) {
+		account.CloudId = String(strconv.Itoa(ref.CloudId))
+	}
+
+	return account, nil
+}
+
+func (s *Client) findCloudAccountByRef(ref CloudAccountRef) (*CloudAccount, error) {
+
+	if ref.Id != 0 {
+		return s.GetCloudAccount(ref.TenantId, ref.CloudId, ref.Id)
+	}
+
+	if ref.DisplayName == "" {
+		return nil, errors.New("CloudAccountRef.Id and CloudAccountRef.DisplayName are missing")
+	}
+
+	accounts, err := s.GetCloudAccounts(ref.TenantId, ref.CloudId)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *CloudAccount
+
+	for i := range accounts {
+		if stringValue(accounts[i].DisplayName) == ref.DisplayName {
+			if found != nil {
+				return nil, errors.New("CLOUD ACCOUNT NAME " + ref.DisplayName + " IS NOT UNIQUE")
+			}
+			found = &accounts[i]
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("CLOUD ACCOUNT NOT FOUND")
+	}
+
+	return found, nil
+}
+
+// credentialedCloudAccount returns a copy of the account with the credentials
+// applied. It fails when the password or a masked property would be sent back
+// without its real value.
+func credentialedCloudAccount(cloudAccount *CloudAccount, creds CloudAccountCredentials) (*CloudAccount, error) {
+
+	if creds.AccountPassword == "" || isMaskedValue(creds.AccountPassword) {
+		return nil, errors.New("CloudAccountCredentials.AccountPassword is missing: the current password cannot be read back")
+	}
+
+	c, err := copyCloudAccount(cloudAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	applyCloudAccountCredentials(c, creds)
+
+	if c.AccountProperties != nil {
+
+		var masked []string
+
+		for _, property := range *c.AccountProperties {
+			if isMaskedValue(stringValue(property.Value)) {
+				masked = append(masked, stringValue(property.Name))
+			}
+		}
+
+		if len(masked) > 0 {
+			return nil, errors.New("CloudAccountCredentials.AccountProperties is missing the masked properties " + strings.Join(masked, ", "))
+		}
+	}
+
+	return c, nil
+}
+
+func applyCloudAccountCredentials(cloudAccount *CloudAccount, creds CloudAccountCredentials) {
+
+	if creds.AccountId != "" {
+		cloudAccount.AccountId = String(creds.AccountId)
+	}
+
+	if creds.AccountPassword != "" {
+		cloudAccount.AccountPassword = String(creds.AccountPassword)
+	}
+
+	if len(creds.AccountProperties) == 0 {
+		return
+	}
+
+	var properties []AccountProperty
+	if cloudAccount.AccountProperties != nil {
+		properties = *cloudAccount.AccountProperties
+	}
+
+	seen := make(map[string]bool)
+
+	for i := range properties {
+		name := stringValue(properties[i].Name)
+		if value, ok := creds.AccountProperties[name]; ok {
+			properties[i].Value = String(value)
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(creds.AccountProperties))
+	for name := range creds.AccountProperties {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		properties = append(properties, AccountProperty{Name: String(name), Value: String(creds.AccountProperties[name])})
+	}
+
+	cloudAccount.AccountProperties = &properties
+}
+
+// verifyCloudAccountCredentials checks that the account read back after the
+// update carries the new credentials. Passwords and secret properties are
+// usually not returned, so only values the server does return are compared.
+func verifyCloudAccountCredentials(cloudAccount *CloudAccount, creds CloudAccountCredentials) error {
+
+	if creds.AccountId != "" && !nonzero(cloudAccount.AccountId) && *cloudAccount.AccountId != creds.AccountId {
+		return errors.New("Cloud account still has AccountId " + *cloudAccount.AccountId)
+	}
+
+	if cloudAccount.AccountProperties == nil {
+		return nil
+	}
+
+	for _, property := range *cloudAccount.AccountProperties {
+		want, ok := creds.AccountProperties[stringValue(property.Name)]
+		if ok && property.Value != nil && *property.Value != want && !isMaskedValue(*property.Value) {
+			return errors.New("Cloud account property " + *property.Name + " was not updated")
+		}
+	}
+
+	return nil
+}
+
+// isMaskedValue reports whether a value returned by the API is a masked secret
+// such as "********".
+func isMaskedValue(value string) bool {
+
+	if value == "" {
+		return false
+	}
+
+	for _, c := range value {
+		if c != '*' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyCloudAccount(cloudAccount *CloudAccount) (*CloudAccount, error) {
+
+	j, err := json.Marshal(cloudAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	var c CloudAccount
+
+	err = json.Unmarshal(j, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,239 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CloudAccountUser is a user the cloud account is shared with.
+type CloudAccountUser struct {
+	Id        int64  `json:"id"`
+	EmailAddr string `json:"emailAddr,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+}
+
+// ShareCloudAccount adds users to the account's AllowedUsers. Users are given
+// as ids or email addresses. A non-empty permission also replaces the account's
+// AccessPermission, which applies to every user it is shared with. Everything
+// else on the account, including users already allowed, is kept. The update
+// sends the whole account, so its current credentials are required: the API
+// does not return the password and masks secret properties.
+func (s *Client) ShareCloudAccount(ctx context.Context, ref CloudAccountRef, creds CloudAccountCredentials, users []string, permission string) (*CloudAccount, error) {
+
+	userIds, err := s.resolveUserIds(users)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.modifyCloudAccountSharing(ctx, ref, creds, func(account *CloudAccount) {
+
+		allowed := allowedUsers(account)
+		for _, userId := range userIds {
+			if !containsInt64(allowed, userId) {
+				allowed = append(allowed, userId)
+			}
+		}
+		account.AllowedUsers = &allowed
+
+		if permission != "" {
+			account.AccessPermission = String(permission)
+		}
+	})
+}
+
+// UnshareCloudAccount removes users, given as ids or email addresses, from the
+// account's AllowedUsers and keeps everything else on the account. Like
+// ShareCloudAccount, it requires the account's current credentials.
+func (s *Client) UnshareCloudAccount(ctx context.Context, ref CloudAccountRef, creds CloudAccountCredentials, users []string) (*CloudAccount, error) {
+
+	userIds, err := s.resolveUserIds(users)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.modifyCloudAccountSharing(ctx, ref, creds, func(account *CloudAccount) {
+
+		allowed := []int64{}
+		for _, userId := range allowedUsers(account) {
+			if !containsInt64(userIds, userId) {
+				allowed = append(allowed, userId)
+			}
+		}
+		account.AllowedUsers = &allowed
+	})
+}
+
+// ListCloudAccountUsers returns the users in the account's AllowedUsers. An
+// account with PublicVisible set may be used by every user of the tenant,
+// whether listed or not.
+func (s *Client) ListCloudAccountUsers(ctx context.Context, ref CloudAccountRef) ([]CloudAccountUser, error) {
+
+	account, err := s.findCloudAccountByRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := allowedUsers(account)
+	users := make([]CloudAccountUser, len(userIds))
+
+	err = forEachConcurrently(ctx, len(userIds), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		user, err := s.GetUser(int(userIds[i]))
+		if err != nil {
+			return err
+		}
+
+		users[i] = CloudAccountUser{
+			Id:        userIds[i],
+			EmailAddr: stringValue(user.EmailAddr),
+			FirstName: stringValue(user.FirstName),
+			LastName:  stringValue(user.LastName),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// modifyCloudAccountSharing applies modify to the account and, when its
+// sharing settings changed, writes back the whole account with creds applied
+// through UpdateCloudAccountSyncWithContext.
+func (s *Client) modifyCloudAccountSharing(ctx context.Context, ref CloudAccountRef, creds CloudAccountCredentials, modify func(account *CloudAccount)) (*CloudAccount, error) {
+
+	current, err := s.getCloudAccountByRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := credentialedCloudAccount(current, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	original := cloudAccountSharingKey(account)
+	modify(account)
+
+	if cloudAccountSharingKey(account) == original {
+		return current, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.UpdateCloudAccountSyncWithContext(ctx, account)
+}
+
+// resolveUserIds turns user ids and email addresses into user ids. Users are
+// listed once, and only when an email address has to be resolved.
+func (s *Client) resolveUserIds(users []string) ([]int64, error) {
+
+	var userIds []int64
+	var usersByEmail map[string]User
+
+	for _, user := range users {
+
+		user = strings.TrimSpace(user)
+
+		if userId, err := strconv.ParseInt(user, 10, 64); err == nil {
+			userIds = append(userIds, userId)
+			continue
+		}
+
+		if !strings.Contains(user, "@") {
+			return nil, errors.New("User " + user + " is neither an id nor an email address")
+		}
+
+		if usersByEmail == nil {
+			all, err := s.getAllUsers()
+			if err != nil {
+				return nil, err
+			}
+			usersByEmail = make(map[string]User, len(all))
+			for _, u := range all {
+				usersByEmail[strings.ToLower(stringValue(u.EmailAddr))] = u
+			}
+		}
+
+		found, ok := usersByEmail[strings.ToLower(user)]
+		if !ok {
+			return nil, errors.New("USER " + user + " NOT FOUND")
+		}
+
+		userId, err := strconv.ParseInt(stringValue(found.Id), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		userIds = append(userIds, userId)
+	}
+
+	return userIds, nil
+}
+
+func allowedUsers(account *CloudAccount) []int64 {
+
+	if account.AllowedUsers == nil {
+		return []int64{}
+	}
+
+	return append([]int64{}, *account.AllowedUsers...)
+}
+
+// cloudAccountSharingKey summarises the sharing settings of an account so a
+// modification can be detected regardless of the order of AllowedUsers.
+func cloudAccountSharingKey(account *CloudAccount) string {
+
+	userIds := allowedUsers(account)
+	sort.Slice(userIds, func(i, j int) bool { return userIds[i] < userIds[j] })
+
+	parts := make([]string, 0, len(userIds)+2)
+	parts = append(parts, stringValue(account.AccessPermission))
+
+	if account.PublicVisible != nil && *account.PublicVisible {
+		parts = append(parts, "public")
+	} else {
+		parts = append(parts, "private")
+	}
+
+	for _, userId := range userIds {
+		parts = append(parts, strconv.FormatInt(userId, 10))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func containsInt64(values []int64, value int64) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,11 +18,14 @@ package cloudcenter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	validator "gopkg.in/validator.v2"
 )
@@ -131,75 +134,35 @@ func (s *Client) GetCloudAccountByName(tenantId int, cloudId int, displayName st
 	return cloudAccounts, nil
 }
 
+// AddCloudAccountSync creates the cloud account and waits up to
+// defaultOperationTimeout for the operation to finish.
 func (s *Client) AddCloudAccountSync(cloudAccount *CloudAccount) (*CloudAccount, error) {
 
-	if errs := validator.Validate(cloudAccount); errs != nil {
-		return nil, errs
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+	defer cancel()
 
-	cloudAccountTenantId := *cloudAccount.TenantId
-	cloudAccountCloudId := *cloudAccount.CloudId
-	url := fmt.Sprintf(s.BaseURL + "/v1/tenants/" + cloudAccountTenantId + "/clouds/" + cloudAccountCloudId + "/accounts")
+	return s.AddCloudAccountSyncWithContext(ctx, cloudAccount)
+}
 
-	j, err := json.Marshal(cloudAccount)
+// AddCloudAccountSyncWithContext creates the cloud account, waits for the
+// operation until it finishes or ctx is done and returns the created account.
+func (s *Client) AddCloudAccountSyncWithContext(ctx context.Context, cloudAccount *CloudAccount) (*CloudAccount, error) {
+	return s.addCloudAccountAndWait(ctx, cloudAccount, defaultPollInterval)
+}
 
-	if err != nil {
-		return nil, err
-	}
+func (s *Client) addCloudAccountAndWait(ctx context.Context, cloudAccount *CloudAccount, pollInterval time.Duration) (*CloudAccount, error) {
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(j))
+	operation, err := s.AddCloudAccountAsync(cloudAccount)
 	if err != nil {
 		return nil, err
 	}
 
-	bytes, err := s.doRequest(req)
-
+	status, err := s.WaitForOperation(ctx, operation, pollInterval)
 	if err != nil {
-		return nil, err
-	} else {
-
-		var status map[string]interface{}
-
-		json.Unmarshal(bytes, &status)
-
-		for status["status"] == "RUNNING" {
-
-			url := fmt.Sprintf(status["resourceUrl"].(string))
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				return nil, err
-			}
-			bytes, err = s.doRequest(req)
-			if err != nil {
-				return nil, err
-			}
-
-			json.Unmarshal(bytes, &status)
-
-		}
-
-		if status["status"] == "SUCCESS" {
-			cloudAccountDisplayName := *cloudAccount.DisplayName
-			cloudAccounts, err := s.GetCloudAccountByName(1, 1, cloudAccountDisplayName)
-
-			if err != nil {
-				return nil, err
-			} else {
-
-				for _, cloudAccount := range cloudAccounts {
-
-					return &cloudAccount, nil
-				}
-			}
-		} else {
-
-			return nil, errors.New("Cloud Account creation failed")
-
-		}
+		return nil, errors.New("Cloud Account creation failed: " + err.Error())
 	}
 
-	return nil, errors.New("Cloud Account creation failed")
-
+	return s.getCloudAccountForOperation(status, cloudAccount)
 }
 
 func (s *Client) AddCloudAccountAsync(cloudAccount *CloudAccount) (*OperationStatus, error) {
@@ -241,8 +204,37 @@ func (s *Client) AddCloudAccountAsync(cloudAccount *CloudAccount) (*OperationSta
 
 }
 
+// UpdateCloudAccountSync updates the cloud account and waits up to
+// defaultOperationTimeout for the operation to finish.
 func (s *Client) UpdateCloudAccountSync(cloudAccount *CloudAccount) (*CloudAccount, error) {
 
+	ctx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+	defer cancel()
+
+	return s.UpdateCloudAccountSyncWithContext(ctx, cloudAccount)
+}
+
+// UpdateCloudAccountSyncWithContext updates the cloud account, waits for the
+// operation until it finishes or ctx is done and returns the updated account.
+func (s *Client) UpdateCloudAccountSyncWithContext(ctx context.Context, cloudAccount *CloudAccount) (*CloudAccount, error) {
+
+	operation, err := s.UpdateCloudAccountAsync(cloudAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.WaitForOperation(ctx, operation, defaultPollInterval)
+	if err != nil {
+		return nil, errors.New("Cloud Account update failed: " + err.Error())
+	}
+
+	return s.getCloudAccountForOperation(status, cloudAccount)
+}
+
+func (s *Client) UpdateCloudAccountAsync(cloudAccount *CloudAccount) (*OperationStatus, error) {
+
+	var data OperationStatus
+
 	if errs := validator.Validate(cloudAccount); errs != nil {
 		return nil, errs
 	}
@@ -271,93 +263,69 @@ func (s *Client) UpdateCloudAccountSync(cloudAccount *CloudAccount) (*CloudAccou
 
 	if err != nil {
 		return nil, err
-	} else {
-
-		var status map[string]interface{}
-
-		json.Unmarshal(bytes, &status)
-
-		for status["status"] == "RUNNING" {
-
-			url := fmt.Sprintf(status["resourceUrl"].(string))
-			req, err := http.NewRequest("GET", url, nil)
-			if err != nil {
-				return nil, err
-			}
-			bytes, err = s.doRequest(req)
-			if err != nil {
-				return nil, err
-			}
-
-			json.Unmarshal(bytes, &status)
-
-		}
+	}
 
-		if status["status"] == "SUCCESS" {
-			cloudAccountDisplayName := *cloudAccount.DisplayName
-			cloudAccounts, err := s.GetCloudAccountByName(1, 1, cloudAccountDisplayName)
+	err = json.Unmarshal(bytes, &data)
 
-			if err != nil {
-				return nil, err
-			} else {
+	if err != nil {
+		return nil, err
+	}
 
-				for _, cloudAccount := range cloudAccounts {
+	return &data, nil
+}
 
-					return &cloudAccount, nil
-				}
-			}
-		} else {
+// ValidateCloudAccount checks the account's credentials against its cloud
+// without saving anything. The account does not need an Id.
+func (s *Client) ValidateCloudAccount(cloudAccount *CloudAccount) error {
 
-			return nil, errors.New("Cloud Account creation failed")
-
-		}
-	}
-
-	return nil, errors.New("Cloud Account creation failed")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+	defer cancel()
 
+	return s.ValidateCloudAccountWithContext(ctx, cloudAccount)
 }
 
-func (s *Client) UpdateCloudAccountAsync(cloudAccount *CloudAccount) (*OperationStatus, error) {
-
-	var data OperationStatus
+// ValidateCloudAccountWithContext is ValidateCloudAccount waiting for an
+// asynchronous validation until it finishes or ctx is done.
+func (s *Client) ValidateCloudAccountWithContext(ctx context.Context, cloudAccount *CloudAccount) error {
 
 	if errs := validator.Validate(cloudAccount); errs != nil {
-		return nil, errs
-	}
-
-	if nonzero(cloudAccount.Id) {
-		return nil, errors.New("CloudAccount.Id is missing")
+		return errs
 	}
 
-	cloudAccountTenantId := *cloudAccount.TenantId
-	cloudAccountCloudId := *cloudAccount.CloudId
-	cloudAccountId := *cloudAccount.Id
-	url := fmt.Sprintf(s.BaseURL + "/v1/tenants/" + cloudAccountTenantId + "/clouds/" + cloudAccountCloudId + "/accounts/" + cloudAccountId)
+	url := fmt.Sprintf(s.BaseURL + "/v1/tenants/" + *cloudAccount.TenantId + "/clouds/" + *cloudAccount.CloudId + "/accounts/validate")
 
 	j, err := json.Marshal(cloudAccount)
+	if err != nil {
+		return err
+	}
 
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(j))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(j))
+	body, err := s.doRequest(req)
 	if err != nil {
-		return nil, err
+		return errors.New("Cloud Account validation failed: " + err.Error())
 	}
 
-	bytes, err := s.doRequest(req)
+	var status OperationStatus
 
-	if err != nil {
-		return nil, err
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &status) != nil || status.Status == nil {
+		return nil
 	}
 
-	err = json.Unmarshal(bytes, &data)
+	if *status.Status == "RUNNING" && !(nonzero(status.Id) && nonzero(status.OperationId) && nonzero(status.ResourceUrl)) {
+		_, err = s.WaitForOperation(ctx, &status, defaultPollInterval)
+	} else if *status.Status != "SUCCESS" {
+		err = errors.New(stringValue(status.Msg))
+	}
 
 	if err != nil {
-		return nil, err
+		return errors.New("Cloud Account validation failed: " + err.Error())
 	}
 
-	return &data, nil
+	return nil
 }
 
 func (s *Client) DeleteCloudAccount(tenantId int, cloudId int, accountId int) error {
@@ -375,3 +343,82 @@ func (s *Client) DeleteCloudAccount(tenantId int, cloudId int, accountId int) er
 
 	return nil
 }
+
+// getCloudAccountForOperation fetches the account a finished create or update
+// operation refers to: the operation's resource URL when it has one, the
+// account's Id otherwise, and as a last resort the single account of the
+// tenant's cloud with the requested DisplayName.
+func (s *Client) getCloudAccountForOperation(status *OperationStatus, cloudAccount *CloudAccount) (*CloudAccount, error) {
+
+	if status.Resource != nil && strings.Contains(*status.Resource, "/accounts/") {
+
+		url := *status.Resource
+		if !strings.HasPrefix(url, "http") {
+			url = s.BaseURL + url
+		}
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var data CloudAccount
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !nonzero(data.Id) {
+			return &data, nil
+		}
+	}
+
+	tenantId, err := strconv.Atoi(*cloudAccount.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudId, err := strconv.Atoi(*cloudAccount.CloudId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !nonzero(cloudAccount.Id) {
+		accountId, err := strconv.Atoi(*cloudAccount.Id)
+		if err != nil {
+			return nil, err
+		}
+		return s.GetCloudAccount(tenantId, cloudId, accountId)
+	}
+
+	if nonzero(cloudAccount.DisplayName) {
+		return nil, errors.New("CloudAccount.DisplayName is missing")
+	}
+
+	cloudAccounts, err := s.GetCloudAccounts(tenantId, cloudId)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *CloudAccount
+
+	for i := range cloudAccounts {
+		if stringValue(cloudAccounts[i].DisplayName) == *cloudAccount.DisplayName {
+			if found != nil {
+				return nil, errors.New("CLOUD ACCOUNT NAME " + *cloudAccount.DisplayName + " IS NOT UNIQUE")
+			}
+			found = &cloudAccounts[i]
+		}
+	}
+
+	if found == nil {
+		return nil, errors.New("CLOUD ACCOUNT NOT FOUND")
+	}
+
+	return found, nil
+}
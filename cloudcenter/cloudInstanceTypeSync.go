@@ -0,0 +1,271 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// InstanceTypeSyncChange is one instance type that a catalog sync added,
+// removed or changed. Before is nil for added entries and After is nil for
+// removed ones. Kept is false when the change was reverted.
+type InstanceTypeSyncChange struct {
+	Type   string             `json:"type"`
+	Name   string             `json:"name"`
+	Before *CloudInstanceType `json:"before,omitempty"`
+	After  *CloudInstanceType `json:"after,omitempty"`
+	Fields []FieldChange      `json:"fields,omitempty"`
+	Kept   bool               `json:"kept"`
+}
+
+// PriceChanged reports whether the sync changed the entry's CostPerHour.
+func (c InstanceTypeSyncChange) PriceChanged() bool {
+
+	if c.Before == nil || c.After == nil {
+		return false
+	}
+
+	if c.Before.CostPerHour == nil || c.After.CostPerHour == nil {
+		return c.Before.CostPerHour != c.After.CostPerHour
+	}
+
+	return *c.Before.CostPerHour != *c.After.CostPerHour
+}
+
+type InstanceTypeSyncReport struct {
+	TenantId int                      `json:"tenantId"`
+	CloudId  int                      `json:"cloudId"`
+	RegionId int                      `json:"regionId"`
+	Changes  []InstanceTypeSyncChange `json:"changes"`
+}
+
+// SyncCloudInstanceTypesWithReport runs SyncCloudInstanceTypes, reports the
+// entries it added, removed or changed by comparing the catalog before and
+// after the sync, and then reverts every change the selector does not keep.
+// A nil selector keeps every change. CloudCenter has no read-only sync, so a
+// preview is a sync whose selector keeps nothing; the reverted changes can be
+// applied later with ApplyInstanceTypeSync. Removed entries that are reverted
+// are created again with new ids and returned.
+func (s *Client) SyncCloudInstanceTypesWithReport(ctx context.Context, tenantId int, cloudId int, regionId int, keep func(change InstanceTypeSyncChange) bool) (*InstanceTypeSyncReport, []RecreatedInstanceType, error) {
+
+	before, err := s.GetCloudInstanceTypes(tenantId, cloudId, regionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	beforeByName, err := instanceTypesByName(before)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	_, err = s.SyncCloudInstanceTypes(tenantId, cloudId, regionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := s.GetCloudInstanceTypes(tenantId, cloudId, regionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	afterByName, err := instanceTypesByName(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &InstanceTypeSyncReport{
+		TenantId: tenantId,
+		CloudId:  cloudId,
+		RegionId: regionId,
+	}
+
+	for _, name := range unionKeys(beforeByName, afterByName) {
+
+		b, inBefore := beforeByName[name]
+		a, inAfter := afterByName[name]
+
+		switch {
+		case inBefore && !inAfter:
+			report.Changes = append(report.Changes, InstanceTypeSyncChange{Type: TopologyRemoved, Name: name, Before: &b})
+		case !inBefore && inAfter:
+			report.Changes = append(report.Changes, InstanceTypeSyncChange{Type: TopologyAdded, Name: name, After: &a})
+		default:
+			if fields := diffFields(b, a, topologyIgnoredFields); len(fields) > 0 {
+				report.Changes = append(report.Changes, InstanceTypeSyncChange{Type: TopologyChanged, Name: name, Before: &b, After: &a, Fields: fields})
+			}
+		}
+	}
+
+	for i := range report.Changes {
+		report.Changes[i].Kept = keep == nil || keep(report.Changes[i])
+	}
+
+	recreated, err := s.RevertInstanceTypeSync(ctx, report, func(change InstanceTypeSyncChange) bool {
+		return !change.Kept
+	})
+	if err != nil {
+		return report, recreated, fmt.Errorf("Reverting the unselected changes failed, the catalog is partly synced: %s", err)
+	}
+
+	return report, recreated, nil
+}
+
+// RecreatedInstanceType is an instance type that ApplyInstanceTypeSync or
+// RevertInstanceTypeSync had to create again. It gets a new id, so image
+// mappings and other references to OldId no longer point to it.
+type RecreatedInstanceType struct {
+	Name  string `json:"name"`
+	OldId string `json:"oldId"`
+	NewId string `json:"newId"`
+}
+
+// ApplyInstanceTypeSync brings every selected change of the report to its
+// post-sync state, e.g. changes SyncCloudInstanceTypesWithReport reverted. A
+// nil selector selects every change. Entries that have to be created again
+// are returned: their ids change.
+func (s *Client) ApplyInstanceTypeSync(ctx context.Context, report *InstanceTypeSyncReport, selected func(change InstanceTypeSyncChange) bool) ([]RecreatedInstanceType, error) {
+
+	return s.setInstanceTypes(ctx, report, selected, func(change InstanceTypeSyncChange) *CloudInstanceType {
+		return change.After
+	})
+}
+
+// RevertInstanceTypeSync brings every selected change of the report back to
+// its pre-sync state, restoring custom prices or other settings the sync
+// overwrote. Changed entries keep their ids. Entries the sync removed are
+// created again with new ids and returned, as references to their old ids,
+// such as image mappings, are broken. A nil selector selects every change.
+func (s *Client) RevertInstanceTypeSync(ctx context.Context, report *InstanceTypeSyncReport, selected func(change InstanceTypeSyncChange) bool) ([]RecreatedInstanceType, error) {
+
+	return s.setInstanceTypes(ctx, report, selected, func(change InstanceTypeSyncChange) *CloudInstanceType {
+		return change.Before
+	})
+}
+
+func (s *Client) setInstanceTypes(ctx context.Context, report *InstanceTypeSyncReport, selected func(change InstanceTypeSyncChange) bool, target func(change InstanceTypeSyncChange) *CloudInstanceType) ([]RecreatedInstanceType, error) {
+
+	live, err := s.GetCloudInstanceTypes(report.TenantId, report.CloudId, report.RegionId)
+	if err != nil {
+		return nil, err
+	}
+
+	liveByName, err := instanceTypesByName(live)
+	if err != nil {
+		return nil, err
+	}
+
+	var recreated []RecreatedInstanceType
+
+	for _, change := range report.Changes {
+
+		if selected != nil && !selected(change) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return recreated, err
+		}
+
+		want := target(change)
+		current, exists := liveByName[change.Name]
+
+		switch {
+		case want == nil && exists:
+			id, err := strconv.Atoi(stringValue(current.Id))
+			if err != nil {
+				return recreated, err
+			}
+			err = s.DeleteCloudInstanceType(report.TenantId, report.CloudId, report.RegionId, id)
+			if err != nil {
+				return recreated, fmt.Errorf("Deleting instance type %s failed: %s", change.Name, err)
+			}
+
+		case want != nil && !exists:
+			instanceType := *want
+			instanceType.Id = nil
+			instanceType.Resource = nil
+			setInstanceTypeScope(&instanceType, report)
+			newInstanceType, err := s.AddCloudInstanceType(&instanceType)
+			if err != nil {
+				return recreated, fmt.Errorf("Adding instance type %s failed: %s", change.Name, err)
+			}
+			recreated = append(recreated, RecreatedInstanceType{
+				Name:  change.Name,
+				OldId: stringValue(want.Id),
+				NewId: stringValue(newInstanceType.Id),
+			})
+
+		case want != nil && exists:
+			instanceType := *want
+			instanceType.Id = current.Id
+			instanceType.Resource = current.Resource
+			setInstanceTypeScope(&instanceType, report)
+			_, err := s.UpdateCloudInstanceType(&instanceType)
+			if err != nil {
+				return recreated, fmt.Errorf("Updating instance type %s failed: %s", change.Name, err)
+			}
+		}
+	}
+
+	return recreated, nil
+}
+
+func setInstanceTypeScope(instanceType *CloudInstanceType, report *InstanceTypeSyncReport) {
+	instanceType.TenantId = String(strconv.Itoa(report.TenantId))
+	instanceType.CloudId = String(strconv.Itoa(report.CloudId))
+	instanceType.RegionId = String(strconv.Itoa(report.RegionId))
+}
+
+// WriteText writes one line per added (+), removed (-) or changed (~) entry,
+// followed by the changed fields.
+func (r *InstanceTypeSyncReport) WriteText(w io.Writer) error {
+
+	diff := &TopologyDiff{}
+
+	for _, change := range r.Changes {
+		diff.Changes = append(diff.Changes, TopologyChange{
+			Type:   change.Type,
+			Kind:   "instanceType",
+			Path:   change.Name,
+			Fields: change.Fields,
+		})
+	}
+
+	return diff.WriteText(w)
+}
+
+func instanceTypesByName(instanceTypes []CloudInstanceType) (map[string]CloudInstanceType, error) {
+
+	byName := make(map[string]CloudInstanceType, len(instanceTypes))
+
+	for _, instanceType := range instanceTypes {
+		name := stringValue(instanceType.Name)
+		if _, ok := byName[name]; ok {
+			return nil, duplicateTopologyItem("instance type", name)
+		}
+		byName[name] = instanceType
+	}
+
+	return byName, nil
+}
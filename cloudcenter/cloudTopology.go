@@ -0,0 +1,211 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+)
+
+// CloudTopology is a point-in-time copy of a tenant's clouds and everything
+// configured below them. Cloud account passwords are never included.
+type CloudTopology struct {
+	TenantId   string          `json:"tenantId"`
+	CapturedAt string          `json:"capturedAt"`
+	Clouds     []CloudSnapshot `json:"clouds"`
+
+	// Images maps the ids of the images the image mappings refer to to their
+	// names, so mappings can be matched across CCMs.
+	Images map[string]string `json:"images,omitempty"`
+}
+
+type CloudSnapshot struct {
+	Cloud    Cloud            `json:"cloud"`
+	Accounts []CloudAccount   `json:"accounts,omitempty"`
+	Regions  []RegionSnapshot `json:"regions,omitempty"`
+}
+
+type RegionSnapshot struct {
+	Region        CloudRegion         `json:"region"`
+	InstanceTypes []CloudInstanceType `json:"instanceTypes,omitempty"`
+	StorageTypes  []CloudStorageType  `json:"storageTypes,omitempty"`
+	ImageMappings []CloudImageMapping `json:"imageMappings,omitempty"`
+}
+
+// SnapshotCloudTopology fetches the tenant's clouds, their accounts and
+// regions, each region's instance types, storage types and image mappings,
+// and the names of the tenant's images. Requests are issued concurrently. Account
+// passwords and property values are left out.
+func (s *Client) SnapshotCloudTopology(ctx context.Context, tenantId int) (*CloudTopology, error) {
+
+	clouds, err := s.GetClouds(tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := s.GetImages(tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &CloudTopology{
+		TenantId:   strconv.Itoa(tenantId),
+		CapturedAt: time.Now().UTC().Format(time.RFC3339),
+		Clouds:     make([]CloudSnapshot, len(clouds)),
+		Images:     make(map[string]string),
+	}
+
+	for _, image := range images {
+		topology.Images[stringValue(image.Id)] = stringValue(image.Name)
+	}
+
+	err = forEachConcurrently(ctx, len(clouds), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		cloud := clouds[i]
+		snapshot := &topology.Clouds[i]
+		snapshot.Cloud = cloud
+
+		cloudId, err := strconv.Atoi(stringValue(cloud.Id))
+		if err != nil {
+			return err
+		}
+
+		accounts, err := s.GetCloudAccounts(tenantId, cloudId)
+		if err != nil {
+			return err
+		}
+
+		for i := range accounts {
+			stripCloudAccountSecrets(&accounts[i])
+		}
+		snapshot.Accounts = accounts
+
+		regions, err := s.GetCloudRegions(tenantId, cloudId)
+		if err != nil {
+			return err
+		}
+
+		snapshot.Regions = make([]RegionSnapshot, len(regions))
+
+		return forEachConcurrently(ctx, len(regions), defaultConcurrency, func(ctx context.Context, j int) error {
+
+			region := &snapshot.Regions[j]
+			region.Region = regions[j]
+
+			regionId, err := strconv.Atoi(stringValue(regions[j].Id))
+			if err != nil {
+				return err
+			}
+
+			region.InstanceTypes, err = s.GetCloudInstanceTypes(tenantId, cloudId, regionId)
+			if err != nil {
+				return err
+			}
+
+			region.StorageTypes, err = s.GetCloudStorageTypes(tenantId, cloudId, regionId)
+			if err != nil {
+				return err
+			}
+
+			region.ImageMappings, err = s.GetCloudImageMappings(tenantId, cloudId, regionId)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return topology, nil
+}
+
+func (t *CloudTopology) WriteJSON(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(t)
+}
+
+func (t *CloudTopology) WriteYAML(w io.Writer) error {
+
+	b, err := marshalYAML(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// SaveCloudTopology writes the topology to filename as YAML when the name ends
+// in .yaml or .yml and as JSON otherwise.
+func SaveCloudTopology(filename string, topology *CloudTopology) error {
+
+	var b []byte
+	var err error
+
+	if isYAMLFile(filename) {
+		b, err = marshalYAML(topology)
+	} else {
+		b, err = json.MarshalIndent(topology, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// LoadCloudTopology reads a topology saved by SaveCloudTopology.
+func LoadCloudTopology(filename string) (*CloudTopology, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var topology CloudTopology
+
+	err = unmarshalByExtension(filename, b, &topology)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topology, nil
+}
+
+// stripCloudAccountSecrets removes the password and the values of the account
+// properties, which hold secrets such as secret keys and client secrets, so
+// snapshots can be stored and shared. Property names are kept.
+func stripCloudAccountSecrets(account *CloudAccount) {
+
+	account.AccountPassword = nil
+
+	if account.AccountProperties == nil {
+		return
+	}
+
+	properties := make([]AccountProperty, 0, len(*account.AccountProperties))
+	for _, property := range *account.AccountProperties {
+		properties = append(properties, AccountProperty{Name: property.Name})
+	}
+	account.AccountProperties = &properties
+}
@@ -0,0 +1,433 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+const (
+	TopologyAdded   = "ADDED"
+	TopologyRemoved = "REMOVED"
+	TopologyChanged = "CHANGED"
+)
+
+// TopologyChange describes one item that differs between two topologies.
+// Path identifies the item by name, e.g. "AWS/us-east-1/m4.large".
+type TopologyChange struct {
+	Type   string        `json:"type"`
+	Kind   string        `json:"kind"`
+	Path   string        `json:"path"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type TopologyDiff struct {
+	Changes []TopologyChange `json:"changes"`
+}
+
+// topologyIgnoredFields are server-assigned or runtime fields that differ
+// between CCMs (or over time) without being a configuration change.
+var topologyIgnoredFields = map[string]bool{
+	"id":            true,
+	"resource":      true,
+	"perms":         true,
+	"tenantId":      true,
+	"cloudId":       true,
+	"regionId":      true,
+	"cloudRegionId": true,
+	"status":        true,
+	"statusDetail":  true,
+	"numUsers":      true,
+	"canDelete":     true,
+	"detail":        true,
+}
+
+// imageMappingIgnoredFields are the fields of image mappings that refer to
+// images by server-assigned id; mappings are matched by image name instead.
+var imageMappingIgnoredFields = map[string]bool{
+	"imageId":      true,
+	"cloudImageId": true,
+}
+
+// DiffCloudTopology compares two topologies, typically a saved one and a live
+// one, or staging and production. Items are matched by name rather than id so
+// snapshots taken from different CCMs can be compared: image mappings by the
+// name of their image, looked up in the topology's Images. Server-assigned
+// fields are ignored at every level, including inside nested lists. Two items
+// of the same kind with the same name in one topology are reported as an
+// error.
+func DiffCloudTopology(before *CloudTopology, after *CloudTopology) (*TopologyDiff, error) {
+
+	diff := &TopologyDiff{}
+
+	beforeClouds := make(map[string]CloudSnapshot)
+	for _, cloud := range before.Clouds {
+		name := stringValue(cloud.Cloud.Name)
+		if _, ok := beforeClouds[name]; ok {
+			return nil, duplicateTopologyItem("cloud", name)
+		}
+		beforeClouds[name] = cloud
+	}
+
+	afterClouds := make(map[string]CloudSnapshot)
+	for _, cloud := range after.Clouds {
+		name := stringValue(cloud.Cloud.Name)
+		if _, ok := afterClouds[name]; ok {
+			return nil, duplicateTopologyItem("cloud", name)
+		}
+		afterClouds[name] = cloud
+	}
+
+	for _, name := range unionKeys(beforeClouds, afterClouds) {
+
+		b, inBefore := beforeClouds[name]
+		a, inAfter := afterClouds[name]
+
+		diff.compare("cloud", name, inBefore, inAfter, b.Cloud, a.Cloud)
+
+		if !inBefore || !inAfter {
+			continue
+		}
+
+		err := diff.compareNamed("account", name+"/", len(b.Accounts), len(a.Accounts),
+			func(i int) (string, interface{}) { return stringValue(b.Accounts[i].DisplayName), b.Accounts[i] },
+			func(i int) (string, interface{}) { return stringValue(a.Accounts[i].DisplayName), a.Accounts[i] })
+		if err != nil {
+			return nil, err
+		}
+
+		beforeRegions := make(map[string]RegionSnapshot)
+		for _, region := range b.Regions {
+			key := regionKey(region.Region)
+			if _, ok := beforeRegions[key]; ok {
+				return nil, duplicateTopologyItem("region", name+"/"+key)
+			}
+			beforeRegions[key] = region
+		}
+		afterRegions := make(map[string]RegionSnapshot)
+		for _, region := range a.Regions {
+			key := regionKey(region.Region)
+			if _, ok := afterRegions[key]; ok {
+				return nil, duplicateTopologyItem("region", name+"/"+key)
+			}
+			afterRegions[key] = region
+		}
+
+		for _, regionName := range unionKeys(beforeRegions, afterRegions) {
+
+			br, inBefore := beforeRegions[regionName]
+			ar, inAfter := afterRegions[regionName]
+			path := name + "/" + regionName
+
+			diff.compare("region", path, inBefore, inAfter, br.Region, ar.Region)
+
+			if !inBefore || !inAfter {
+				continue
+			}
+
+			err := diff.compareNamed("instanceType", path+"/", len(br.InstanceTypes), len(ar.InstanceTypes),
+				func(i int) (string, interface{}) { return stringValue(br.InstanceTypes[i].Name), br.InstanceTypes[i] },
+				func(i int) (string, interface{}) { return stringValue(ar.InstanceTypes[i].Name), ar.InstanceTypes[i] })
+			if err != nil {
+				return nil, err
+			}
+
+			err = diff.compareNamed("storageType", path+"/", len(br.StorageTypes), len(ar.StorageTypes),
+				func(i int) (string, interface{}) { return stringValue(br.StorageTypes[i].Name), br.StorageTypes[i] },
+				func(i int) (string, interface{}) { return stringValue(ar.StorageTypes[i].Name), ar.StorageTypes[i] })
+			if err != nil {
+				return nil, err
+			}
+
+			err = diff.compareNamed("imageMapping", path+"/", len(br.ImageMappings), len(ar.ImageMappings),
+				func(i int) (string, interface{}) {
+					return before.imageName(br.ImageMappings[i].ImageId), normalizeImageMapping(br.ImageMappings[i])
+				},
+				func(i int) (string, interface{}) {
+					return after.imageName(ar.ImageMappings[i].ImageId), normalizeImageMapping(ar.ImageMappings[i])
+				})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// imageName returns the name of the image with the given id, or "image-<id>"
+// for snapshots that do not record it.
+func (t *CloudTopology) imageName(imageId *string) string {
+
+	if name, ok := t.Images[stringValue(imageId)]; ok && name != "" {
+		return name
+	}
+
+	return "image-" + stringValue(imageId)
+}
+
+// normalizeImageMapping sorts the mapping's instance type mappings by
+// instance type name so their order does not show as a change.
+func normalizeImageMapping(mapping CloudImageMapping) CloudImageMapping {
+
+	if mapping.Mappings == nil {
+		return mapping
+	}
+
+	mappings := append([]Mapping{}, *mapping.Mappings...)
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return instanceTypeName(mappings[i].CloudInstanceType) < instanceTypeName(mappings[j].CloudInstanceType)
+	})
+	mapping.Mappings = &mappings
+
+	return mapping
+}
+
+func instanceTypeName(instanceType *CloudInstanceType) string {
+
+	if instanceType == nil {
+		return ""
+	}
+
+	return stringValue(instanceType.Name)
+}
+
+func duplicateTopologyItem(kind string, path string) error {
+	return fmt.Errorf("More than one %s is named %s", kind, path)
+}
+
+func (d *TopologyDiff) HasChanges() bool {
+	return len(d.Changes) > 0
+}
+
+func (d *TopologyDiff) WriteJSON(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(d)
+}
+
+// WriteText writes one line per added (+), removed (-) or changed (~) item,
+// followed by the changed fields.
+func (d *TopologyDiff) WriteText(w io.Writer) error {
+
+	if !d.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+
+	symbols := map[string]string{
+		TopologyAdded:   "+",
+		TopologyRemoved: "-",
+		TopologyChanged: "~",
+	}
+
+	for _, change := range d.Changes {
+
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", symbols[change.Type], change.Kind, change.Path); err != nil {
+			return err
+		}
+
+		for _, field := range change.Fields {
+			if _, err := fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, formatFieldValue(field.Old), formatFieldValue(field.New)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareNamed compares the items of two lists matched by the names returned
+// by before and after.
+func (d *TopologyDiff) compareNamed(kind string, prefix string, beforeCount int, afterCount int, before func(i int) (string, interface{}), after func(i int) (string, interface{})) error {
+
+	beforeItems := make(map[string]interface{})
+	for i := 0; i < beforeCount; i++ {
+		name, item := before(i)
+		if _, ok := beforeItems[name]; ok {
+			return duplicateTopologyItem(kind, prefix+name)
+		}
+		beforeItems[name] = item
+	}
+
+	afterItems := make(map[string]interface{})
+	for i := 0; i < afterCount; i++ {
+		name, item := after(i)
+		if _, ok := afterItems[name]; ok {
+			return duplicateTopologyItem(kind, prefix+name)
+		}
+		afterItems[name] = item
+	}
+
+	for _, name := range unionKeys(beforeItems, afterItems) {
+		b, inBefore := beforeItems[name]
+		a, inAfter := afterItems[name]
+		d.compare(kind, prefix+name, inBefore, inAfter, b, a)
+	}
+
+	return nil
+}
+
+func (d *TopologyDiff) compare(kind string, path string, inBefore bool, inAfter bool, before interface{}, after interface{}) {
+
+	switch {
+	case inBefore && !inAfter:
+		d.Changes = append(d.Changes, TopologyChange{Type: TopologyRemoved, Kind: kind, Path: path})
+	case !inBefore && inAfter:
+		d.Changes = append(d.Changes, TopologyChange{Type: TopologyAdded, Kind: kind, Path: path})
+	default:
+		ignored := topologyIgnoredFields
+		if kind == "imageMapping" {
+			ignored = make(map[string]bool)
+			for field := range topologyIgnoredFields {
+				ignored[field] = true
+			}
+			for field := range imageMappingIgnoredFields {
+				ignored[field] = true
+			}
+		}
+		fields := diffFields(before, after, ignored)
+		if len(fields) > 0 {
+			d.Changes = append(d.Changes, TopologyChange{Type: TopologyChanged, Kind: kind, Path: path, Fields: fields})
+		}
+	}
+}
+
+func regionKey(region CloudRegion) string {
+
+	if region.RegionName != nil {
+		return *region.RegionName
+	}
+
+	return stringValue(region.DisplayName)
+}
+
+// diffFields compares the JSON representations of two values and returns the
+// differing fields, using dotted names for nested objects. Fields listed in
+// ignored are skipped at every level, including in objects nested in lists.
+func diffFields(before interface{}, after interface{}, ignored map[string]bool) []FieldChange {
+
+	b := toJSONMap(before)
+	a := toJSONMap(after)
+
+	stripFields(b, ignored)
+	stripFields(a, ignored)
+
+	var changes []FieldChange
+	collectFieldChanges("", b, a, &changes)
+
+	return changes
+}
+
+// stripFields removes the ignored fields from every object in v.
+func stripFields(v interface{}, ignored map[string]bool) {
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for field := range ignored {
+			delete(value, field)
+		}
+		for _, item := range value {
+			stripFields(item, ignored)
+		}
+	case []interface{}:
+		for _, item := range value {
+			stripFields(item, ignored)
+		}
+	}
+}
+
+func collectFieldChanges(prefix string, before map[string]interface{}, after map[string]interface{}, changes *[]FieldChange) {
+
+	for _, key := range unionKeys(before, after) {
+
+		b := before[key]
+		a := after[key]
+
+		bm, bIsMap := b.(map[string]interface{})
+		am, aIsMap := a.(map[string]interface{})
+
+		if bIsMap && aIsMap {
+			collectFieldChanges(prefix+key+".", bm, am, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(b, a) {
+			*changes = append(*changes, FieldChange{Field: prefix + key, Old: b, New: a})
+		}
+	}
+}
+
+func toJSONMap(v interface{}) map[string]interface{} {
+
+	m := make(map[string]interface{})
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+
+	json.Unmarshal(j, &m)
+
+	return m
+}
+
+func formatFieldValue(v interface{}) string {
+
+	if v == nil {
+		return "<unset>"
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(j)
+}
+
+// unionKeys returns the keys of both maps, sorted.
+func unionKeys(maps ...interface{}) []string {
+
+	seen := make(map[string]bool)
+
+	for _, m := range maps {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			seen[key.String()] = true
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
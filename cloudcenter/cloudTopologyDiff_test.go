@@ -0,0 +1,201 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testTopology returns a topology with one AWS cloud, one region, one
+// instance type and one image mapping. The ids depend on idPrefix so two
+// topologies can differ only by server-assigned ids.
+func testTopology(idPrefix string) *CloudTopology {
+
+	return &CloudTopology{
+		Clouds: []CloudSnapshot{{
+			Cloud: Cloud{Id: String(idPrefix + "1"), Name: String("AWS")},
+			Regions: []RegionSnapshot{{
+				Region: CloudRegion{Id: String(idPrefix + "2"), RegionName: String("us-east-1"), DisplayName: String("US East")},
+				InstanceTypes: []CloudInstanceType{
+					{Id: String(idPrefix + "3"), Name: String("m4.large"), CostPerHour: Float64(0.1)},
+				},
+				ImageMappings: []CloudImageMapping{{
+					Id:             String(idPrefix + "4"),
+					ImageId:        String(idPrefix + "5"),
+					LaunchUserName: String("centos"),
+					Mappings: &[]Mapping{
+						{CloudInstanceType: &CloudInstanceType{Name: String("m4.large")}},
+						{CloudInstanceType: &CloudInstanceType{Name: String("m4.xlarge")}},
+					},
+				}},
+			}},
+		}},
+		Images: map[string]string{idPrefix + "5": "CentOS 7"},
+	}
+}
+
+func TestDiffCloudTopology(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		modify func(after *CloudTopology)
+		want   []string
+	}{
+		{
+			name:   "only ids differ",
+			modify: func(after *CloudTopology) {},
+		},
+		{
+			name: "price changed",
+			modify: func(after *CloudTopology) {
+				after.Clouds[0].Regions[0].InstanceTypes[0].CostPerHour = Float64(0.2)
+			},
+			want: []string{"CHANGED instanceType AWS/us-east-1/m4.large costPerHour"},
+		},
+		{
+			name: "instance type added",
+			modify: func(after *CloudTopology) {
+				region := &after.Clouds[0].Regions[0]
+				region.InstanceTypes = append(region.InstanceTypes, CloudInstanceType{Name: String("m4.xlarge")})
+			},
+			want: []string{"ADDED instanceType AWS/us-east-1/m4.xlarge"},
+		},
+		{
+			name: "instance type removed",
+			modify: func(after *CloudTopology) {
+				after.Clouds[0].Regions[0].InstanceTypes = nil
+			},
+			want: []string{"REMOVED instanceType AWS/us-east-1/m4.large"},
+		},
+		{
+			name: "region removed without its contents",
+			modify: func(after *CloudTopology) {
+				after.Clouds[0].Regions = nil
+			},
+			want: []string{"REMOVED region AWS/us-east-1"},
+		},
+		{
+			name: "cloud added",
+			modify: func(after *CloudTopology) {
+				after.Clouds = append(after.Clouds, CloudSnapshot{Cloud: Cloud{Name: String("Azure")}})
+			},
+			want: []string{"ADDED cloud Azure"},
+		},
+		{
+			name: "image mapping changed",
+			modify: func(after *CloudTopology) {
+				after.Clouds[0].Regions[0].ImageMappings[0].LaunchUserName = String("ec2-user")
+			},
+			want: []string{"CHANGED imageMapping AWS/us-east-1/CentOS 7 launchUserName"},
+		},
+		{
+			name: "image mapping order of instance types",
+			modify: func(after *CloudTopology) {
+				mappings := *after.Clouds[0].Regions[0].ImageMappings[0].Mappings
+				mappings[0], mappings[1] = mappings[1], mappings[0]
+			},
+		},
+		{
+			name: "image renamed",
+			modify: func(after *CloudTopology) {
+				after.Images["b5"] = "CentOS 8"
+			},
+			want: []string{
+				"REMOVED imageMapping AWS/us-east-1/CentOS 7",
+				"ADDED imageMapping AWS/us-east-1/CentOS 8",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			after := testTopology("b")
+			test.modify(after)
+
+			diff, err := DiffCloudTopology(testTopology("a"), after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, change := range diff.Changes {
+				summary := change.Type + " " + change.Kind + " " + change.Path
+				for _, field := range change.Fields {
+					summary += " " + field.Field
+				}
+				got = append(got, summary)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changes = %q, want %q", got, test.want)
+			}
+
+			if diff.HasChanges() != (len(test.want) > 0) {
+				t.Errorf("HasChanges() = %v", diff.HasChanges())
+			}
+		})
+	}
+}
+
+func TestDiffCloudTopologyDuplicates(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		modify func(topology *CloudTopology)
+		want   string
+	}{
+		{
+			name: "cloud",
+			modify: func(topology *CloudTopology) {
+				topology.Clouds = append(topology.Clouds, CloudSnapshot{Cloud: Cloud{Name: String("AWS")}})
+			},
+			want: "cloud",
+		},
+		{
+			name: "region",
+			modify: func(topology *CloudTopology) {
+				cloud := &topology.Clouds[0]
+				cloud.Regions = append(cloud.Regions, RegionSnapshot{Region: CloudRegion{RegionName: String("us-east-1")}})
+			},
+			want: "region",
+		},
+		{
+			name: "instance type",
+			modify: func(topology *CloudTopology) {
+				region := &topology.Clouds[0].Regions[0]
+				region.InstanceTypes = append(region.InstanceTypes, CloudInstanceType{Name: String("m4.large")})
+			},
+			want: "instanceType",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			after := testTopology("b")
+			test.modify(after)
+
+			_, err := DiffCloudTopology(testTopology("a"), after)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want a duplicate %s error", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InstanceOffer is an instance type normalised for comparison across clouds
+// and regions. MemoryGB is converted from the MB reported by CloudCenter.
+type InstanceOffer struct {
+	CloudId      string  `json:"cloudId"`
+	CloudName    string  `json:"cloudName"`
+	CloudFamily  string  `json:"cloudFamily"`
+	RegionId     string  `json:"regionId"`
+	RegionName   string  `json:"regionName"`
+	Name         string  `json:"name"`
+	CPUs         int64   `json:"cpus"`
+	MemoryGB     float64 `json:"memoryGB"`
+	PricePerHour float64 `json:"pricePerHour"`
+	SSD          bool    `json:"ssd"`
+	CUDA         bool    `json:"cuda"`
+}
+
+// StorageOffer is a storage type normalised for comparison across clouds and
+// regions.
+type StorageOffer struct {
+	CloudId        string  `json:"cloudId"`
+	CloudName      string  `json:"cloudName"`
+	CloudFamily    string  `json:"cloudFamily"`
+	RegionId       string  `json:"regionId"`
+	RegionName     string  `json:"regionName"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	CostPerGBMonth float64 `json:"costPerGBMonth"`
+	MinVolumeGB    int64   `json:"minVolumeGB,omitempty"`
+	MaxVolumeGB    int64   `json:"maxVolumeGB,omitempty"`
+	MaxIOPS        int64   `json:"maxIOPS,omitempty"`
+}
+
+type InstanceOffers []InstanceOffer
+
+type StorageOffers []StorageOffer
+
+// CostReport holds every priced instance and storage type of a tenant.
+type CostReport struct {
+	TenantId   string         `json:"tenantId"`
+	CapturedAt string         `json:"capturedAt"`
+	Instances  InstanceOffers `json:"instances"`
+	Storage    StorageOffers  `json:"storage"`
+}
+
+// InstanceQuery filters instance offers. Cloud matches the cloud's name or
+// family and Region the region's name, both case-insensitively; zero values
+// match everything.
+type InstanceQuery struct {
+	Cloud           string
+	Region          string
+	MinCPUs         int64
+	MinMemoryGB     float64
+	MaxPricePerHour float64
+	SSD             bool
+	CUDA            bool
+}
+
+// StorageQuery filters storage offers the same way as InstanceQuery. MinVolumeGB
+// keeps the types whose maximum volume size is at least that large.
+type StorageQuery struct {
+	Cloud             string
+	Region            string
+	Type              string
+	MinVolumeGB       int64
+	MinIOPS           int64
+	MaxCostPerGBMonth float64
+}
+
+// GetCostReport snapshots the tenant's cloud topology and builds a cost report
+// from it.
+func (s *Client) GetCostReport(ctx context.Context, tenantId int) (*CostReport, error) {
+
+	topology, err := s.SnapshotCloudTopology(ctx, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCostReport(topology), nil
+}
+
+// NewCostReport builds a cost report from a topology, e.g. one loaded with
+// LoadCloudTopology. Instance and storage types without a price are left out.
+func NewCostReport(topology *CloudTopology) *CostReport {
+
+	report := &CostReport{
+		TenantId:   topology.TenantId,
+		CapturedAt: topology.CapturedAt,
+	}
+
+	for _, cloud := range topology.Clouds {
+		for _, region := range cloud.Regions {
+
+			for _, instanceType := range region.InstanceTypes {
+
+				if instanceType.CostPerHour == nil {
+					continue
+				}
+
+				offer := InstanceOffer{
+					CloudId:      stringValue(cloud.Cloud.Id),
+					CloudName:    stringValue(cloud.Cloud.Name),
+					CloudFamily:  stringValue(cloud.Cloud.CloudFamily),
+					RegionId:     stringValue(region.Region.Id),
+					RegionName:   regionKey(region.Region),
+					Name:         stringValue(instanceType.Name),
+					PricePerHour: *instanceType.CostPerHour,
+					SSD:          instanceType.SupportsSSD != nil && *instanceType.SupportsSSD,
+					CUDA:         instanceType.SupportsCUDA != nil && *instanceType.SupportsCUDA,
+				}
+
+				if instanceType.NumOfCPUs != nil {
+					offer.CPUs = *instanceType.NumOfCPUs
+				}
+				if instanceType.MemorySize != nil {
+					offer.MemoryGB = float64(*instanceType.MemorySize) / 1024
+				}
+
+				report.Instances = append(report.Instances, offer)
+			}
+
+			for _, storageType := range region.StorageTypes {
+
+				if storageType.CostPerMonth == nil {
+					continue
+				}
+
+				offer := StorageOffer{
+					CloudId:        stringValue(cloud.Cloud.Id),
+					CloudName:      stringValue(cloud.Cloud.Name),
+					CloudFamily:    stringValue(cloud.Cloud.CloudFamily),
+					RegionId:       stringValue(region.Region.Id),
+					RegionName:     regionKey(region.Region),
+					Name:           stringValue(storageType.Name),
+					Type:           stringValue(storageType.Type),
+					CostPerGBMonth: *storageType.CostPerMonth,
+				}
+
+				if storageType.MinVolumeSize != nil {
+					offer.MinVolumeGB = *storageType.MinVolumeSize
+				}
+				if storageType.MaxVolumeSize != nil {
+					offer.MaxVolumeGB = *storageType.MaxVolumeSize
+				}
+				if storageType.MaxIOPS != nil {
+					offer.MaxIOPS = *storageType.MaxIOPS
+				}
+
+				report.Storage = append(report.Storage, offer)
+			}
+		}
+	}
+
+	return report
+}
+
+// FindInstances returns the instance offers matching the query, cheapest first.
+func (r *CostReport) FindInstances(query InstanceQuery) InstanceOffers {
+
+	var offers InstanceOffers
+
+	for _, offer := range r.Instances {
+
+		switch {
+		case !matchesCloud(query.Cloud, offer.CloudName, offer.CloudFamily):
+		case query.Region != "" && !strings.EqualFold(query.Region, offer.RegionName):
+		case offer.CPUs < query.MinCPUs:
+		case offer.MemoryGB < query.MinMemoryGB:
+		case query.MaxPricePerHour > 0 && offer.PricePerHour > query.MaxPricePerHour:
+		case query.SSD && !offer.SSD:
+		case query.CUDA && !offer.CUDA:
+		default:
+			offers = append(offers, offer)
+		}
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].PricePerHour < offers[j].PricePerHour
+	})
+
+	return offers
+}
+
+// CheapestInstance returns the cheapest instance offer matching the query, or
+// nil when none does.
+func (r *CostReport) CheapestInstance(query InstanceQuery) *InstanceOffer {
+
+	offers := r.FindInstances(query)
+	if len(offers) == 0 {
+		return nil
+	}
+
+	return &offers[0]
+}
+
+// FindStorage returns the storage offers matching the query, cheapest first.
+func (r *CostReport) FindStorage(query StorageQuery) StorageOffers {
+
+	var offers StorageOffers
+
+	for _, offer := range r.Storage {
+
+		switch {
+		case !matchesCloud(query.Cloud, offer.CloudName, offer.CloudFamily):
+		case query.Region != "" && !strings.EqualFold(query.Region, offer.RegionName):
+		case query.Type != "" && !strings.EqualFold(query.Type, offer.Type):
+		case query.MinVolumeGB > 0 && offer.MaxVolumeGB > 0 && offer.MaxVolumeGB < query.MinVolumeGB:
+		case offer.MaxIOPS < query.MinIOPS:
+		case query.MaxCostPerGBMonth > 0 && offer.CostPerGBMonth > query.MaxCostPerGBMonth:
+		default:
+			offers = append(offers, offer)
+		}
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].CostPerGBMonth < offers[j].CostPerGBMonth
+	})
+
+	return offers
+}
+
+// CheapestStorage returns the cheapest storage offer matching the query, or nil
+// when none does.
+func (r *CostReport) CheapestStorage(query StorageQuery) *StorageOffer {
+
+	offers := r.FindStorage(query)
+	if len(offers) == 0 {
+		return nil
+	}
+
+	return &offers[0]
+}
+
+func (r *CostReport) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, r)
+}
+
+func (o InstanceOffers) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, o)
+}
+
+func (o StorageOffers) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, o)
+}
+
+// WriteCSV writes the offers with a header row.
+func (o InstanceOffers) WriteCSV(w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"cloud", "cloudFamily", "region", "name", "cpus", "memoryGB", "pricePerHour", "ssd", "cuda"})
+
+	for _, offer := range o {
+		writer.Write([]string{
+			offer.CloudName,
+			offer.CloudFamily,
+			offer.RegionName,
+			offer.Name,
+			strconv.FormatInt(offer.CPUs, 10),
+			strconv.FormatFloat(offer.MemoryGB, 'f', -1, 64),
+			strconv.FormatFloat(offer.PricePerHour, 'f', -1, 64),
+			strconv.FormatBool(offer.SSD),
+			strconv.FormatBool(offer.CUDA),
+		})
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// WriteCSV writes the offers with a header row.
+func (o StorageOffers) WriteCSV(w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+
+	writer.Write([]string{"cloud", "cloudFamily", "region", "name", "type", "costPerGBMonth", "minVolumeGB", "maxVolumeGB", "maxIOPS"})
+
+	for _, offer := range o {
+		writer.Write([]string{
+			offer.CloudName,
+			offer.CloudFamily,
+			offer.RegionName,
+			offer.Name,
+			offer.Type,
+			strconv.FormatFloat(offer.CostPerGBMonth, 'f', -1, 64),
+			strconv.FormatInt(offer.MinVolumeGB, 10),
+			strconv.FormatInt(offer.MaxVolumeGB, 10),
+			strconv.FormatInt(offer.MaxIOPS, 10),
+		})
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func matchesCloud(query string, name string, family string) bool {
+	return query == "" || strings.EqualFold(query, name) || strings.EqualFold(query, family)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
@@ -0,0 +1,100 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// desiredFieldChanges returns the fields set in desired whose value differs
+// from live. Fields left unset in desired are not managed and never reported.
+// The ignored fields, typically assigned by the server, are left out at every
+// level, so lists whose elements get ids from the server compare equal to the
+// same lists without them.
+func desiredFieldChanges(live interface{}, desired interface{}, ignored map[string]bool) []FieldChange {
+
+	l := toJSONMap(live)
+	d := toJSONMap(desired)
+
+	stripFields(l, ignored)
+	stripFields(d, ignored)
+
+	var changes []FieldChange
+	collectDesiredChanges("", l, d, &changes)
+
+	return changes
+}
+
+func collectDesiredChanges(prefix string, live map[string]interface{}, desired map[string]interface{}, changes *[]FieldChange) {
+
+	for _, key := range unionKeys(desired) {
+
+		d := desired[key]
+		l := live[key]
+
+		dm, dIsMap := d.(map[string]interface{})
+		lm, lIsMap := l.(map[string]interface{})
+
+		if dIsMap && lIsMap {
+			collectDesiredChanges(prefix+key+".", lm, dm, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(l, d) {
+			*changes = append(*changes, FieldChange{Field: prefix + key, Old: l, New: d})
+		}
+	}
+}
+
+// mergeDesired overlays the fields set in desired onto live and decodes the
+// result into out. The ignored fields of desired are not applied, so live's ids
+// and other server-assigned fields are kept.
+func mergeDesired(live interface{}, desired interface{}, out interface{}, ignored map[string]bool) error {
+
+	merged := toJSONMap(live)
+	d := toJSONMap(desired)
+
+	for field := range ignored {
+		delete(d, field)
+	}
+
+	overlayJSONMap(merged, d)
+
+	j, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(j, out)
+}
+
+func overlayJSONMap(dst map[string]interface{}, src map[string]interface{}) {
+
+	for key, value := range src {
+
+		sm, sIsMap := value.(map[string]interface{})
+		dm, dIsMap := dst[key].(map[string]interface{})
+
+		if sIsMap && dIsMap {
+			overlayJSONMap(dm, sm)
+			continue
+		}
+
+		dst[key] = value
+	}
+}
@@ -0,0 +1,532 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DirectorySource is implemented by anything that can list the users and
+// groups of an external identity directory (LDAP, a static file, ...).
+type DirectorySource interface {
+	ListUsers() ([]DirectoryUser, error)
+	ListGroups() ([]DirectoryGroup, error)
+}
+
+// DirectoryUser is a user as seen by the external directory. Users are matched
+// to CloudCenter users by email address.
+type DirectoryUser struct {
+	EmailAddr   string `json:"emailAddr"`
+	FirstName   string `json:"firstName,omitempty"`
+	LastName    string `json:"lastName,omitempty"`
+	CompanyName string `json:"companyName,omitempty"`
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	ExternalId  string `json:"externalId,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// DirectoryGroup is a group as seen by the external directory. Members holds
+// the email addresses of the group's users.
+type DirectoryGroup struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Members     []string `json:"members,omitempty"`
+}
+
+type DirectorySyncOptions struct {
+	TenantId int
+
+	// DryRun computes and returns the plan without changing anything.
+	DryRun bool
+
+	// DisableMissing disables CloudCenter users of the tenant that are not
+	// present in the directory.
+	DisableMissing bool
+
+	// Output, when set, receives one line per change as it is planned or applied.
+	Output io.Writer
+}
+
+const (
+	DirectorySyncAddUser     = "ADD_USER"
+	DirectorySyncUpdateUser  = "UPDATE_USER"
+	DirectorySyncDisableUser = "DISABLE_USER"
+	DirectorySyncAddGroup    = "ADD_GROUP"
+	DirectorySyncUpdateGroup = "UPDATE_GROUP"
+)
+
+type DirectorySyncChange struct {
+	Action string
+	Name   string
+	Detail string
+
+	user  *User
+	group *Group
+	// members holds the email addresses a group should contain; they are
+	// resolved to user ids at apply time so newly added users can be included.
+	members []string
+}
+
+func (c DirectorySyncChange) String() string {
+	if c.Detail == "" {
+		return c.Action + " " + c.Name
+	}
+	return c.Action + " " + c.Name + " (" + c.Detail + ")"
+}
+
+type DirectorySyncPlan struct {
+	TenantId int
+	Changes  []DirectorySyncChange
+}
+
+func (p *DirectorySyncPlan) String() string {
+	if len(p.Changes) == 0 {
+		return "No changes\n"
+	}
+	var b strings.Builder
+	for _, change := range p.Changes {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// StaticDirectorySource is a DirectorySource backed by an in-memory list of
+// users and groups, typically loaded from a JSON file.
+type StaticDirectorySource struct {
+	Users  []DirectoryUser  `json:"users"`
+	Groups []DirectoryGroup `json:"groups"`
+}
+
+func (d *StaticDirectorySource) ListUsers() ([]DirectoryUser, error) {
+	return d.Users, nil
+}
+
+func (d *StaticDirectorySource) ListGroups() ([]DirectoryGroup, error) {
+	return d.Groups, nil
+}
+
+// LoadStaticDirectorySource reads a JSON document of the form
+// {"users": [...], "groups": [...]}.
+func LoadStaticDirectorySource(filename string) (*StaticDirectorySource, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var source StaticDirectorySource
+
+	err = json.Unmarshal(b, &source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &source, nil
+}
+
+// PlanDirectorySync compares the directory with the tenant's users and groups
+// and returns the changes required to make CloudCenter mirror it. Every page of
+// users is read. Group members must be enabled users once the plan is applied;
+// a member that is disabled, skipped or unknown is an error.
+func (s *Client) PlanDirectorySync(ctx context.Context, source DirectorySource, opts DirectorySyncOptions) (*DirectorySyncPlan, error) {
+
+	dirUsers, err := source.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	dirGroups, err := source.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	users, err := s.getAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.GetGroups(opts.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantId := strconv.Itoa(opts.TenantId)
+
+	existingUsers := make(map[string]User)
+	for _, user := range users {
+		if stringValue(user.TenantId) != tenantId || user.EmailAddr == nil {
+			continue
+		}
+		existingUsers[strings.ToLower(*user.EmailAddr)] = user
+	}
+
+	plan := &DirectorySyncPlan{TenantId: opts.TenantId}
+	seen := make(map[string]bool)
+
+	for _, dirUser := range dirUsers {
+
+		if dirUser.EmailAddr == "" {
+			return nil, errors.New("DirectoryUser.EmailAddr is missing")
+		}
+
+		key := strings.ToLower(dirUser.EmailAddr)
+		seen[key] = true
+
+		existing, ok := existingUsers[key]
+		if !ok {
+			if dirUser.Disabled {
+				continue
+			}
+			plan.Changes = append(plan.Changes, DirectorySyncChange{
+				Action: DirectorySyncAddUser,
+				Name:   dirUser.EmailAddr,
+				user:   newUserFromDirectory(dirUser, tenantId),
+			})
+			continue
+		}
+
+		updated, diffs := mergeDirectoryUser(existing, dirUser)
+		if len(diffs) > 0 {
+			plan.Changes = append(plan.Changes, DirectorySyncChange{
+				Action: DirectorySyncUpdateUser,
+				Name:   dirUser.EmailAddr,
+				Detail: strings.Join(diffs, ", "),
+				user:   updated,
+			})
+		}
+	}
+
+	if opts.DisableMissing {
+
+		keys := make([]string, 0, len(existingUsers))
+		for key := range existingUsers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			existing := existingUsers[key]
+			if seen[key] || (existing.Enabled != nil && !*existing.Enabled) {
+				continue
+			}
+			disabled := existing
+			disabled.Enabled = Bool(false)
+			disabled.DisableReason = String("Removed from directory")
+			plan.Changes = append(plan.Changes, DirectorySyncChange{
+				Action: DirectorySyncDisableUser,
+				Name:   stringValue(existing.EmailAddr),
+				user:   &disabled,
+			})
+		}
+	}
+
+	// users that will be enabled users of the tenant once the plan is
+	// applied, the only ones groups may contain
+	enabledAfter := make(map[string]bool)
+	for _, dirUser := range dirUsers {
+		enabledAfter[strings.ToLower(dirUser.EmailAddr)] = !dirUser.Disabled
+	}
+	for key, existing := range existingUsers {
+		if !seen[key] {
+			enabledAfter[key] = !opts.DisableMissing && (existing.Enabled == nil || *existing.Enabled)
+		}
+	}
+
+	for _, dirGroup := range dirGroups {
+		for _, member := range dirGroup.Members {
+			key := strings.ToLower(member)
+			if enabled, ok := enabledAfter[key]; !ok {
+				return nil, fmt.Errorf("Group %s: member %s is neither in the directory nor a user of tenant %s", dirGroup.Name, member, tenantId)
+			} else if !enabled {
+				return nil, fmt.Errorf("Group %s: member %s is disabled or not synced", dirGroup.Name, member)
+			}
+		}
+	}
+
+	existingGroups := make(map[string]Group)
+	for _, group := range groups {
+		existingGroups[stringValue(group.Name)] = group
+	}
+
+	for _, dirGroup := range dirGroups {
+
+		if dirGroup.Name == "" {
+			return nil, errors.New("DirectoryGroup.Name is missing")
+		}
+
+		existing, ok := existingGroups[dirGroup.Name]
+		if !ok {
+			plan.Changes = append(plan.Changes, DirectorySyncChange{
+				Action: DirectorySyncAddGroup,
+				Name:   dirGroup.Name,
+				Detail: fmt.Sprintf("%d members", len(dirGroup.Members)),
+				group: &Group{
+					Name:        String(dirGroup.Name),
+					Description: String(dirGroup.Description),
+					TenantId:    String(tenantId),
+				},
+				members: dirGroup.Members,
+			})
+			continue
+		}
+
+		current := make(map[string]bool)
+		if existing.Users != nil {
+			for _, user := range *existing.Users {
+				if user.EmailAddr != nil {
+					current[strings.ToLower(*user.EmailAddr)] = true
+				}
+			}
+		}
+
+		var diffs []string
+		wanted := make(map[string]bool)
+		for _, member := range dirGroup.Members {
+			key := strings.ToLower(member)
+			wanted[key] = true
+			if !current[key] {
+				diffs = append(diffs, "+"+member)
+			}
+		}
+		for key := range current {
+			if !wanted[key] {
+				diffs = append(diffs, "-"+key)
+			}
+		}
+		if dirGroup.Description != stringValue(existing.Description) {
+			diffs = append(diffs, "description")
+		}
+
+		if len(diffs) > 0 {
+			sort.Strings(diffs)
+			updated := existing
+			updated.Description = String(dirGroup.Description)
+			if updated.TenantId == nil {
+				updated.TenantId = String(tenantId)
+			}
+			plan.Changes = append(plan.Changes, DirectorySyncChange{
+				Action:  DirectorySyncUpdateGroup,
+				Name:    dirGroup.Name,
+				Detail:  strings.Join(diffs, ", "),
+				group:   &updated,
+				members: dirGroup.Members,
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// SyncDirectory makes the tenant's users and groups mirror the directory. With
+// opts.DryRun set the plan is returned (and written to opts.Output) unapplied.
+func (s *Client) SyncDirectory(ctx context.Context, source DirectorySource, opts DirectorySyncOptions) (*DirectorySyncPlan, error) {
+
+	plan, err := s.PlanDirectorySync(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		if opts.Output != nil {
+			fmt.Fprint(opts.Output, plan.String())
+		}
+		return plan, nil
+	}
+
+	return plan, s.ApplyDirectorySync(ctx, plan, opts.Output)
+}
+
+// ApplyDirectorySync applies a plan returned by PlanDirectorySync. User changes
+// are applied before group changes so new users can be added to groups.
+func (s *Client) ApplyDirectorySync(ctx context.Context, plan *DirectorySyncPlan, output io.Writer) error {
+
+	for _, change := range plan.Changes {
+
+		if change.user == nil {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		if change.Action == DirectorySyncAddUser {
+			_, err = s.AddUser(change.user)
+		} else {
+			_, err = s.UpdateUser(change.user)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", change, err)
+		}
+
+		if output != nil {
+			fmt.Fprintln(output, change.String())
+		}
+	}
+
+	hasGroups := false
+	for _, change := range plan.Changes {
+		if change.group != nil {
+			hasGroups = true
+		}
+	}
+	if !hasGroups {
+		return nil
+	}
+
+	users, err := s.getAllUsers()
+	if err != nil {
+		return err
+	}
+
+	tenantId := strconv.Itoa(plan.TenantId)
+	usersByEmail := make(map[string]User)
+	for _, user := range users {
+		if stringValue(user.TenantId) == tenantId && user.EmailAddr != nil {
+			usersByEmail[strings.ToLower(*user.EmailAddr)] = user
+		}
+	}
+
+	for _, change := range plan.Changes {
+
+		if change.group == nil {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		members := make([]User, 0, len(change.members))
+		for _, member := range change.members {
+			user, ok := usersByEmail[strings.ToLower(member)]
+			if !ok {
+				return fmt.Errorf("%s: member %s is not a user of tenant %s", change, member, tenantId)
+			}
+			members = append(members, User{Id: user.Id})
+		}
+
+		group := *change.group
+		group.Users = &members
+
+		if change.Action == DirectorySyncAddGroup {
+			_, err = s.AddGroup(&group)
+		} else {
+			_, err = s.UpdateGroup(&group)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", change, err)
+		}
+
+		if output != nil {
+			fmt.Fprintln(output, change.String())
+		}
+	}
+
+	return nil
+}
+
+func newUserFromDirectory(dirUser DirectoryUser, tenantId string) *User {
+
+	user := &User{
+		EmailAddr: String(dirUser.EmailAddr),
+		TenantId:  String(tenantId),
+	}
+
+	if dirUser.FirstName != "" {
+		user.FirstName = String(dirUser.FirstName)
+	}
+	if dirUser.LastName != "" {
+		user.LastName = String(dirUser.LastName)
+	}
+	if dirUser.CompanyName != "" {
+		user.CompanyName = String(dirUser.CompanyName)
+	}
+	if dirUser.PhoneNumber != "" {
+		user.PhoneNumber = String(dirUser.PhoneNumber)
+	}
+	if dirUser.ExternalId != "" {
+		user.ExternalId = String(dirUser.ExternalId)
+	}
+	if dirUser.Password != "" {
+		user.Password = String(dirUser.Password)
+	}
+
+	return user
+}
+
+// mergeDirectoryUser returns a copy of existing updated from the directory,
+// together with the names of the fields that changed.
+func mergeDirectoryUser(existing User, dirUser DirectoryUser) (*User, []string) {
+
+	updated := existing
+	// never send the stored password hash back
+	updated.Password = nil
+
+	var diffs []string
+
+	fields := []struct {
+		name  string
+		value string
+		field **string
+	}{
+		{"firstName", dirUser.FirstName, &updated.FirstName},
+		{"lastName", dirUser.LastName, &updated.LastName},
+		{"companyName", dirUser.CompanyName, &updated.CompanyName},
+		{"phoneNumber", dirUser.PhoneNumber, &updated.PhoneNumber},
+		{"externalId", dirUser.ExternalId, &updated.ExternalId},
+	}
+
+	for _, f := range fields {
+		if f.value != "" && f.value != stringValue(*f.field) {
+			*f.field = String(f.value)
+			diffs = append(diffs, f.name)
+		}
+	}
+
+	enabled := existing.Enabled == nil || *existing.Enabled
+	if dirUser.Disabled && enabled {
+		updated.Enabled = Bool(false)
+		updated.DisableReason = String("Disabled in directory")
+		diffs = append(diffs, "disabled")
+	} else if !dirUser.Disabled && !enabled {
+		updated.Enabled = Bool(true)
+		updated.DisableReason = nil
+		diffs = append(diffs, "enabled")
+	}
+
+	return &updated, diffs
+}
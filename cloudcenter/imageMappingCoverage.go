@@ -0,0 +1,334 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ImageCoverageRegion is one column of the coverage matrix.
+type ImageCoverageRegion struct {
+	CloudId    string `json:"cloudId"`
+	CloudName  string `json:"cloudName"`
+	RegionId   string `json:"regionId"`
+	RegionName string `json:"regionName"`
+}
+
+// Key returns the "cloud/region" name used in an ImageMappingTable.
+func (r ImageCoverageRegion) Key() string {
+	return r.CloudName + "/" + r.RegionName
+}
+
+// ImageCoverageRow is one row of the coverage matrix. Mappings holds one entry
+// per region of the coverage, nil where the image is not mapped.
+type ImageCoverageRow struct {
+	Image    Image                `json:"image"`
+	Mappings []*CloudImageMapping `json:"mappings"`
+}
+
+// ImageMappingCoverage is a matrix of the tenant's images against every region
+// of every cloud.
+type ImageMappingCoverage struct {
+	TenantId int                   `json:"tenantId"`
+	Regions  []ImageCoverageRegion `json:"regions"`
+	Rows     []ImageCoverageRow    `json:"rows"`
+}
+
+// ImageMappingGap is an image without a mapping in a region.
+type ImageMappingGap struct {
+	Image  Image               `json:"image"`
+	Region ImageCoverageRegion `json:"region"`
+}
+
+// ImageMappingTable maps an image name and a "cloud/region" key to the cloud
+// provider's image id, e.g. table["CentOS 7"]["AWS/us-east-1"] = "ami-0ff8a915".
+type ImageMappingTable map[string]map[string]string
+
+type ImageMappingReconcileOptions struct {
+
+	// DryRun reports what would be created without creating it.
+	DryRun bool
+
+	// Output, when set, receives one line per created mapping and mismatch.
+	Output io.Writer
+}
+
+// ImageMappingMismatch is an existing mapping whose CloudProviderImageId
+// differs from the lookup table.
+type ImageMappingMismatch struct {
+	Image    Image               `json:"image"`
+	Region   ImageCoverageRegion `json:"region"`
+	Mapping  CloudImageMapping   `json:"mapping"`
+	Expected string              `json:"expected"`
+}
+
+type ImageMappingReconcileResult struct {
+
+	// Created holds the mappings created, or that would be created on a dry run.
+	Created []CloudImageMapping `json:"created,omitempty"`
+
+	// Unresolved holds the gaps the lookup table has no entry for.
+	Unresolved []ImageMappingGap `json:"unresolved,omitempty"`
+
+	Mismatched []ImageMappingMismatch `json:"mismatched,omitempty"`
+}
+
+// GetImageMappingCoverage fetches the tenant's images, clouds, regions and
+// image mappings and returns which images are mapped in which regions.
+// Requests are issued concurrently.
+func (s *Client) GetImageMappingCoverage(ctx context.Context, tenantId int) (*ImageMappingCoverage, error) {
+
+	images, err := s.GetImages(tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	clouds, err := s.GetClouds(tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	regionsByCloud := make([][]ImageCoverageRegion, len(clouds))
+	mappingsByCloud := make([][][]CloudImageMapping, len(clouds))
+
+	err = forEachConcurrently(ctx, len(clouds), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		cloudId, err := strconv.Atoi(stringValue(clouds[i].Id))
+		if err != nil {
+			return err
+		}
+
+		regions, err := s.GetCloudRegions(tenantId, cloudId)
+		if err != nil {
+			return err
+		}
+
+		regionsByCloud[i] = make([]ImageCoverageRegion, len(regions))
+		mappingsByCloud[i] = make([][]CloudImageMapping, len(regions))
+
+		return forEachConcurrently(ctx, len(regions), defaultConcurrency, func(ctx context.Context, j int) error {
+
+			regionsByCloud[i][j] = ImageCoverageRegion{
+				CloudId:    stringValue(clouds[i].Id),
+				CloudName:  stringValue(clouds[i].Name),
+				RegionId:   stringValue(regions[j].Id),
+				RegionName: regionKey(regions[j]),
+			}
+
+			regionId, err := strconv.Atoi(stringValue(regions[j].Id))
+			if err != nil {
+				return err
+			}
+
+			mappingsByCloud[i][j], err = s.GetCloudImageMappings(tenantId, cloudId, regionId)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := &ImageMappingCoverage{TenantId: tenantId}
+
+	// mappingsByImage[imageId][column] is the image's mapping in that region
+	mappingsByImage := make(map[string]map[int]*CloudImageMapping)
+
+	for i := range regionsByCloud {
+		for j := range regionsByCloud[i] {
+
+			column := len(coverage.Regions)
+			coverage.Regions = append(coverage.Regions, regionsByCloud[i][j])
+
+			for k := range mappingsByCloud[i][j] {
+				mapping := &mappingsByCloud[i][j][k]
+				imageId := stringValue(mapping.ImageId)
+				if mappingsByImage[imageId] == nil {
+					mappingsByImage[imageId] = make(map[int]*CloudImageMapping)
+				}
+				mappingsByImage[imageId][column] = mapping
+			}
+		}
+	}
+
+	for _, image := range images {
+
+		row := ImageCoverageRow{
+			Image:    image,
+			Mappings: make([]*CloudImageMapping, len(coverage.Regions)),
+		}
+
+		for column, mapping := range mappingsByImage[stringValue(image.Id)] {
+			row.Mappings[column] = mapping
+		}
+
+		coverage.Rows = append(coverage.Rows, row)
+	}
+
+	return coverage, nil
+}
+
+// Gaps returns every image and region pair without a mapping.
+func (c *ImageMappingCoverage) Gaps() []ImageMappingGap {
+
+	var gaps []ImageMappingGap
+
+	for _, row := range c.Rows {
+		for column, mapping := range row.Mappings {
+			if mapping == nil {
+				gaps = append(gaps, ImageMappingGap{Image: row.Image, Region: c.Regions[column]})
+			}
+		}
+	}
+
+	return gaps
+}
+
+func (c *ImageMappingCoverage) WriteJSON(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(c)
+}
+
+// WriteText writes the matrix as a table with one column per "cloud/region",
+// showing the provider image id where the image is mapped and "-" where not.
+func (c *ImageMappingCoverage) WriteText(w io.Writer) error {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprint(tw, "IMAGE")
+	for _, region := range c.Regions {
+		fmt.Fprint(tw, "\t"+region.Key())
+	}
+	fmt.Fprintln(tw)
+
+	for _, row := range c.Rows {
+
+		fmt.Fprint(tw, stringValue(row.Image.Name))
+
+		for _, mapping := range row.Mappings {
+			if mapping == nil {
+				fmt.Fprint(tw, "\t-")
+			} else {
+				fmt.Fprint(tw, "\t"+stringValue(mapping.CloudProviderImageId))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+// LoadImageMappingTable reads a lookup table from a JSON or YAML (.yaml, .yml)
+// file keyed by image name and then by "cloud/region".
+func LoadImageMappingTable(filename string) (ImageMappingTable, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var table ImageMappingTable
+
+	err = unmarshalByExtension(filename, b, &table)
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// ReconcileImageMappings creates the missing image mappings of the tenant from
+// the lookup table and reports the existing mappings whose
+// CloudProviderImageId differs from it. Mismatches are reported only, never
+// changed.
+func (s *Client) ReconcileImageMappings(ctx context.Context, tenantId int, table ImageMappingTable, opts ImageMappingReconcileOptions) (*ImageMappingReconcileResult, error) {
+
+	coverage, err := s.GetImageMappingCoverage(ctx, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImageMappingReconcileResult{}
+
+	for _, row := range coverage.Rows {
+
+		imageName := stringValue(row.Image.Name)
+
+		for column, mapping := range row.Mappings {
+
+			region := coverage.Regions[column]
+			expected, ok := table[imageName][region.Key()]
+
+			if mapping != nil {
+				if ok && stringValue(mapping.CloudProviderImageId) != expected {
+					result.Mismatched = append(result.Mismatched, ImageMappingMismatch{
+						Image:    row.Image,
+						Region:   region,
+						Mapping:  *mapping,
+						Expected: expected,
+					})
+					if opts.Output != nil {
+						fmt.Fprintf(opts.Output, "! %s %s: %s, expected %s\n", imageName, region.Key(), stringValue(mapping.CloudProviderImageId), expected)
+					}
+				}
+				continue
+			}
+
+			if !ok {
+				result.Unresolved = append(result.Unresolved, ImageMappingGap{Image: row.Image, Region: region})
+				continue
+			}
+
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+
+			newMapping := CloudImageMapping{
+				TenantId:             String(strconv.Itoa(tenantId)),
+				CloudId:              String(region.CloudId),
+				RegionId:             String(region.RegionId),
+				CloudRegionId:        String(region.RegionId),
+				ImageId:              row.Image.Id,
+				CloudProviderImageId: String(expected),
+			}
+
+			if !opts.DryRun {
+				created, err := s.AddCloudImageMapping(&newMapping)
+				if err != nil {
+					return result, fmt.Errorf("Mapping image %s in %s failed: %s", imageName, region.Key(), err)
+				}
+				newMapping = *created
+			}
+
+			result.Created = append(result.Created, newMapping)
+
+			if opts.Output != nil {
+				fmt.Fprintf(opts.Output, "+ %s %s: %s\n", imageName, region.Key(), expected)
+			}
+		}
+	}
+
+	return result, nil
+}
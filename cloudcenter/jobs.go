@@ -281,6 +281,37 @@ func (s *Client) GetJobs() ([]Job, error) {
 	return jobs, nil
 }
 
+// getAllJobs pages through the jobs, as GetJobs only returns the first page.
+func (s *Client) getAllJobs() ([]Job, error) {
+
+	var jobs []Job
+
+	for page := 0; ; page++ {
+
+		url := fmt.Sprintf(s.BaseURL + "/v2/jobs?page=" + strconv.Itoa(page))
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var data JobAPIResponse
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, data.Jobs...)
+
+		if len(data.Jobs) == 0 || data.TotalPages == nil || page+1 >= *data.TotalPages {
+			return jobs, nil
+		}
+	}
+}
+
 func (s *Client) GetJob(id int) (*Job, error) {
 
 	var data Job
@@ -16,19 +16,29 @@ or implied.
 
 package cloudcenter
 
+import "context"
+import "errors"
 import "fmt"
 import "net/http"
+import "time"
 
 import "encoding/json"
 
 //import "strconv"
 //import "bytes"
 
+// defaultPollInterval is used when waiting on operations without an explicit interval.
+const defaultPollInterval = 5 * time.Second
+
+// defaultOperationTimeout bounds the Sync variants of asynchronous calls that take no context.
+const defaultOperationTimeout = 30 * time.Minute
+
 type OperationStatus struct {
 	OperationId          *string                `json:"operationId,omitempty"`
 	Id                   *string                `json:"id,omitempty"`
 	Status               *string                `json:"status,omitempty"`
 	Resource             *string                `json:"resource,omitempty"`
+	ResourceUrl          *string                `json:"resourceUrl,omitempty"`
 	Msg                  *string                `json:"msg,omitempty"`
 	Progress             *int64                 `json:"progress,omitempty"`
 	AdditionalParameters *[]AdditionalParameter `json:"additionalParameters,omitempty"`
@@ -60,3 +70,78 @@ func (s *Client) GetOperationStatus(operationId string) (*OperationStatus, error
 	operationStatus := data
 	return &operationStatus, nil
 }
+
+// WaitForOperation polls an operation returned by an asynchronous call until it
+// is no longer RUNNING, returning an error unless it finished with SUCCESS. The
+// operation's ResourceUrl is polled when set, its Id otherwise.
+func (s *Client) WaitForOperation(ctx context.Context, operation *OperationStatus, pollInterval time.Duration) (*OperationStatus, error) {
+
+	if operation == nil {
+		return nil, errors.New("OperationStatus is missing")
+	}
+
+	operationId := operation.Id
+	if nonzero(operationId) {
+		operationId = operation.OperationId
+	}
+	if nonzero(operationId) && nonzero(operation.ResourceUrl) {
+		return nil, errors.New("OperationStatus.Id is missing")
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	status := operation
+
+	for status.Status == nil || *status.Status == "RUNNING" {
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+
+		next, err := s.pollOperation(status, operationId)
+		if err != nil {
+			return nil, err
+		}
+		status = next
+	}
+
+	if *status.Status != "SUCCESS" {
+		return status, fmt.Errorf("Operation %s finished with status %s: %s", stringValue(operationId), *status.Status, stringValue(status.Msg))
+	}
+
+	return status, nil
+}
+
+func (s *Client) pollOperation(status *OperationStatus, operationId *string) (*OperationStatus, error) {
+
+	if nonzero(status.ResourceUrl) {
+		return s.GetOperationStatus(*operationId)
+	}
+
+	req, err := http.NewRequest("GET", *status.ResourceUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := s.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var data OperationStatus
+
+	err = json.Unmarshal(bytes, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep polling the same URL if the response does not repeat it
+	if nonzero(data.ResourceUrl) {
+		data.ResourceUrl = status.ResourceUrl
+	}
+
+	return &data, nil
+}
@@ -0,0 +1,373 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+const (
+	PolicyKindAging      = "AGING"
+	PolicyKindSuspension = "SUSPENSION"
+)
+
+// JobPolicy is a policy affecting a job, either on the deployment itself or on
+// one of its virtual machines.
+type JobPolicy struct {
+	Kind         string `json:"kind,omitempty"`
+	PolicyId     string `json:"policyId"`
+	PolicyName   string `json:"policyName,omitempty"`
+	Enabled      bool   `json:"enabled"`
+	ResourceId   string `json:"resourceId,omitempty"`
+	ResourceType string `json:"resourceType,omitempty"`
+
+	// Listed reports whether the job's PolicyIds include the policy. Attached
+	// reports whether the policy's resources include the job or one of its
+	// virtual machines. A policy listed on the job but found neither among the
+	// aging nor the suspension policies has an empty Kind.
+	Listed   bool `json:"listed"`
+	Attached bool `json:"attached"`
+}
+
+// ApplyAgingPolicy adds target to the resources of an aging policy and returns
+// the updated policy. Applying a policy to a resource it already covers is a
+// no-op.
+//
+// Extensions of an aging policy cannot be requested: no endpoint to submit a
+// request is documented for this API, only the extension settings and
+// counters of AgingPolicy and AgingPolicyResource.
+func (s *Client) ApplyAgingPolicy(ctx context.Context, policyId int, target ResourceTarget) (*AgingPolicy, error) {
+
+	if err := target.validate(); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.GetAgingPolicy(policyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if findAgingResource(policy, target) >= 0 {
+		return policy, nil
+	}
+
+	resources := []AgingPolicyResource{}
+	if policy.Resources != nil {
+		resources = append(resources, *policy.Resources...)
+	}
+	resources = append(resources, AgingPolicyResource{
+		ResourceId:   String(target.ResourceId),
+		ResourceType: String(target.ResourceType),
+	})
+	policy.Resources = &resources
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.UpdateAgingPolicy(policy)
+}
+
+// ApplySuspensionPolicy adds target to the resources of a suspension policy
+// and returns the updated policy. Applying a policy to a resource it already
+// covers is a no-op.
+func (s *Client) ApplySuspensionPolicy(ctx context.Context, policyId int, target ResourceTarget) (*SuspensionPolicy, error) {
+
+	if err := target.validate(); err != nil {
+		return nil, err
+	}
+
+	policy, err := s.GetSuspensionPolicy(policyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if findSuspensionResource(policy, target) >= 0 {
+		return policy, nil
+	}
+
+	resources := []ResourcesMap{}
+	if policy.ResourcesMaps != nil {
+		resources = append(resources, *policy.ResourcesMaps...)
+	}
+	resources = append(resources, ResourcesMap{
+		ResourceId:   String(target.ResourceId),
+		ResourceType: String(target.ResourceType),
+	})
+	policy.ResourcesMaps = &resources
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.UpdateSuspensionPolicy(policy)
+}
+
+// RemovePolicy removes target from the resources of the aging or suspension
+// policy, as given by kind. Removing a policy from a resource it does not
+// cover is a no-op.
+func (s *Client) RemovePolicy(ctx context.Context, kind string, policyId int, target ResourceTarget) error {
+
+	if err := target.validate(); err != nil {
+		return err
+	}
+
+	switch kind {
+
+	case PolicyKindAging:
+
+		policy, err := s.GetAgingPolicy(policyId)
+		if err != nil {
+			return err
+		}
+
+		i := findAgingResource(policy, target)
+		if i < 0 {
+			return nil
+		}
+
+		resources := append([]AgingPolicyResource{}, (*policy.Resources)[:i]...)
+		resources = append(resources, (*policy.Resources)[i+1:]...)
+		policy.Resources = &resources
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_, err = s.UpdateAgingPolicy(policy)
+		return err
+
+	case PolicyKindSuspension:
+
+		policy, err := s.GetSuspensionPolicy(policyId)
+		if err != nil {
+			return err
+		}
+
+		i := findSuspensionResource(policy, target)
+		if i < 0 {
+			return nil
+		}
+
+		resources := append([]ResourcesMap{}, (*policy.ResourcesMaps)[:i]...)
+		resources = append(resources, (*policy.ResourcesMaps)[i+1:]...)
+		policy.ResourcesMaps = &resources
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_, err = s.UpdateSuspensionPolicy(policy)
+		return err
+	}
+
+	return errors.New("Unknown policy kind " + kind + ", expected " + PolicyKindAging + " or " + PolicyKindSuspension)
+}
+
+// GetJobPolicies reports every aging and suspension policy applied to the job
+// or to one of its virtual machines, as well as the policies listed in the
+// job's PolicyIds. Policies are sorted by kind and id.
+func (s *Client) GetJobPolicies(ctx context.Context, jobId int) ([]JobPolicy, error) {
+
+	job, err := s.GetJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := jobResourceTargets(job)
+
+	agingPolicies, err := s.GetAgingPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	suspensionPolicies, err := s.GetSuspensionPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	// listing does not include the resources of each policy
+	err = forEachConcurrently(ctx, len(agingPolicies)+len(suspensionPolicies), defaultConcurrency, func(ctx context.Context, i int) error {
+
+		if i < len(agingPolicies) {
+			policyId, err := strconv.Atoi(stringValue(agingPolicies[i].Id))
+			if err != nil {
+				return err
+			}
+			policy, err := s.GetAgingPolicy(policyId)
+			if err != nil {
+				return err
+			}
+			agingPolicies[i] = *policy
+			return nil
+		}
+
+		i -= len(agingPolicies)
+
+		policyId, err := strconv.Atoi(stringValue(suspensionPolicies[i].Id))
+		if err != nil {
+			return err
+		}
+		policy, err := s.GetSuspensionPolicy(policyId)
+		if err != nil {
+			return err
+		}
+		suspensionPolicies[i] = *policy
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]bool)
+	if job.PolicyIds != nil {
+		for _, policyId := range *job.PolicyIds {
+			listed[policyId] = true
+		}
+	}
+
+	var policies []JobPolicy
+	known := make(map[string]bool)
+
+	for _, policy := range agingPolicies {
+
+		known[stringValue(policy.Id)] = true
+
+		var resources []ResourceTarget
+		if policy.Resources != nil {
+			for _, resource := range *policy.Resources {
+				resources = append(resources, ResourceTarget{stringValue(resource.ResourceId), stringValue(resource.ResourceType)})
+			}
+		}
+
+		policies = append(policies, jobPolicies(PolicyKindAging, stringValue(policy.Id), stringValue(policy.Name), policy.Enabled, resources, targets, listed)...)
+	}
+
+	for _, policy := range suspensionPolicies {
+
+		known[stringValue(policy.Id)] = true
+
+		var resources []ResourceTarget
+		if policy.ResourcesMaps != nil {
+			for _, resource := range *policy.ResourcesMaps {
+				resources = append(resources, ResourceTarget{stringValue(resource.ResourceId), stringValue(resource.ResourceType)})
+			}
+		}
+
+		policies = append(policies, jobPolicies(PolicyKindSuspension, stringValue(policy.Id), stringValue(policy.Name), policy.Enabled, resources, targets, listed)...)
+	}
+
+	for policyId := range listed {
+		if !known[policyId] {
+			policies = append(policies, JobPolicy{PolicyId: policyId, Listed: true})
+		}
+	}
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Kind != policies[j].Kind {
+			return policies[i].Kind < policies[j].Kind
+		}
+		return policies[i].PolicyId < policies[j].PolicyId
+	})
+
+	return policies, nil
+}
+
+// jobPolicies returns an entry for every resource of the policy among targets,
+// or a single unattached entry when the policy is only listed on the job.
+func jobPolicies(kind string, policyId string, name string, enabled *bool, resources []ResourceTarget, targets map[ResourceTarget]bool, listed map[string]bool) []JobPolicy {
+
+	var policies []JobPolicy
+
+	entry := JobPolicy{
+		Kind:       kind,
+		PolicyId:   policyId,
+		PolicyName: name,
+		Enabled:    enabled == nil || *enabled,
+		Listed:     listed[policyId],
+	}
+
+	for _, resource := range resources {
+		if targets[resource] {
+			attached := entry
+			attached.ResourceId = resource.ResourceId
+			attached.ResourceType = resource.ResourceType
+			attached.Attached = true
+			policies = append(policies, attached)
+		}
+	}
+
+	if len(policies) == 0 && entry.Listed {
+		policies = append(policies, entry)
+	}
+
+	return policies
+}
+
+// jobResourceTargets returns the deployment and virtual machine resources a
+// policy may be applied to for the job.
+func jobResourceTargets(job *Job) map[ResourceTarget]bool {
+
+	targets := map[ResourceTarget]bool{
+		{stringValue(job.Id), ResourceTypeDeployment}: true,
+	}
+
+	if job.VirtualMachines != nil {
+		for _, vm := range *job.VirtualMachines {
+			for _, id := range []*string{vm.Id, vm.VirtualMachineId} {
+				if stringValue(id) != "" {
+					targets[ResourceTarget{stringValue(id), ResourceTypeVirtualMachine}] = true
+				}
+			}
+		}
+	}
+
+	return targets
+}
+
+func findAgingResource(policy *AgingPolicy, target ResourceTarget) int {
+
+	if policy.Resources == nil {
+		return -1
+	}
+
+	for i, resource := range *policy.Resources {
+		if stringValue(resource.ResourceId) == target.ResourceId && stringValue(resource.ResourceType) == target.ResourceType {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func findSuspensionResource(policy *SuspensionPolicy, target ResourceTarget) int {
+
+	if policy.ResourcesMaps == nil {
+		return -1
+	}
+
+	for i, resource := range *policy.ResourcesMaps {
+		if stringValue(resource.ResourceId) == target.ResourceId && stringValue(resource.ResourceType) == target.ResourceType {
+			return i
+		}
+	}
+
+	return -1
+}
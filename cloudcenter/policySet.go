@@ -0,0 +1,748 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PolicySet holds the aging, suspension and action policies of a CloudCenter
+// instance in normalized form: without the fields CloudCenter generates or
+// that only hold runtime state, so that exports of the same policies compare
+// equal. Policies are matched by name.
+type PolicySet struct {
+	AgingPolicies      []AgingPolicy      `json:"agingPolicies,omitempty"`
+	SuspensionPolicies []SuspensionPolicy `json:"suspensionPolicies,omitempty"`
+	ActionPolicies     []ActionPolicy     `json:"actionPolicies,omitempty"`
+}
+
+// PolicyApplyOptions controls ApplyPolicySet.
+type PolicyApplyOptions struct {
+
+	// Prune deletes policies that exist in CloudCenter but are not in the set.
+	Prune bool
+
+	// PlanOnly computes and returns the plan without changing anything.
+	PlanOnly bool
+
+	// Output, when set, receives the plan before it is applied.
+	Output io.Writer
+}
+
+type PolicyPlanStep struct {
+	Action string        `json:"action"`
+	Kind   string        `json:"kind"`
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields,omitempty"`
+
+	run func(s *Client) error
+}
+
+type PolicyPlan struct {
+	Steps []PolicyPlanStep `json:"steps"`
+}
+
+// policyIgnoredFields are generated by CloudCenter or hold the resources a
+// policy is applied to, which are managed with ApplyAgingPolicy,
+// ApplySuspensionPolicy and RemovePolicy rather than with policy files.
+var policyIgnoredFields = map[string]bool{
+	"id":                        true,
+	"resource":                  true,
+	"perms":                     true,
+	"created":                   true,
+	"lastUpdated":               true,
+	"resources":                 true,
+	"resourcesMaps":             true,
+	"isPolicyActiveOnResources": true,
+	"ownerId":                   true,
+	"userId":                    true,
+}
+
+// Subdirectories of a policy directory, one per kind.
+const (
+	agingPolicyDir      = "agingPolicies"
+	suspensionPolicyDir = "suspensionPolicies"
+	actionPolicyDir     = "actionPolicies"
+)
+
+// policyEntry is a policy of any kind, as planned by PlanPolicySet.
+type policyEntry struct {
+	name  string
+	id    string
+	value interface{}
+}
+
+// policyKind reconciles the policies of one kind.
+type policyKind struct {
+	kind    string
+	live    []policyEntry
+	desired []policyEntry
+	add     func(s *Client, desired interface{}) error
+	update  func(s *Client, live interface{}, desired interface{}) error
+	remove  func(id int) error
+}
+
+// GetPolicySet fetches every aging, suspension and action policy, normalized
+// and sorted by name.
+func (s *Client) GetPolicySet(ctx context.Context) (*PolicySet, error) {
+
+	set := &PolicySet{}
+
+	agingPolicies, suspensionPolicies, actionPolicies, err := s.getPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, policy := range agingPolicies {
+		var normalized AgingPolicy
+		if err := normalizePolicy(policy, &normalized); err != nil {
+			return nil, err
+		}
+		set.AgingPolicies = append(set.AgingPolicies, normalized)
+	}
+
+	for _, policy := range suspensionPolicies {
+		var normalized SuspensionPolicy
+		if err := normalizePolicy(policy, &normalized); err != nil {
+			return nil, err
+		}
+		set.SuspensionPolicies = append(set.SuspensionPolicies, normalized)
+	}
+
+	for _, policy := range actionPolicies {
+		var normalized ActionPolicy
+		if err := normalizePolicy(policy, &normalized); err != nil {
+			return nil, err
+		}
+		set.ActionPolicies = append(set.ActionPolicies, normalized)
+	}
+
+	set.sort()
+
+	return set, nil
+}
+
+// ExportPolicies fetches the policy set and writes it to dir with
+// WritePolicyFiles.
+func (s *Client) ExportPolicies(ctx context.Context, dir string, format string) (*PolicySet, error) {
+
+	set, err := s.GetPolicySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return set, set.WritePolicyFiles(dir, format)
+}
+
+// WritePolicyFiles writes one file per policy to the agingPolicies,
+// suspensionPolicies and actionPolicies subdirectories of dir, named after the
+// policy. format is "yaml" or "json". Policy files already in those
+// subdirectories are removed first, so that the directory mirrors the set.
+func (set *PolicySet) WritePolicyFiles(dir string, format string) error {
+
+	format = strings.ToLower(format)
+	if format == "yml" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		return errors.New("Unknown policy file format " + format + ", expected yaml or json")
+	}
+
+	files := map[string][]policyEntry{
+		agingPolicyDir:      nil,
+		suspensionPolicyDir: nil,
+		actionPolicyDir:     nil,
+	}
+
+	for _, policy := range set.AgingPolicies {
+		files[agingPolicyDir] = append(files[agingPolicyDir], policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+	for _, policy := range set.SuspensionPolicies {
+		files[suspensionPolicyDir] = append(files[suspensionPolicyDir], policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+	for _, policy := range set.ActionPolicies {
+		files[actionPolicyDir] = append(files[actionPolicyDir], policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+
+	for subdir, entries := range files {
+
+		path := filepath.Join(dir, subdir)
+
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+
+		existing, err := policyFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, filename := range existing {
+			if err := os.Remove(filename); err != nil {
+				return err
+			}
+		}
+
+		used := make(map[string]bool)
+
+		for _, entry := range entries {
+
+			base := policyFileName(entry.name)
+			filename := base
+			for n := 2; used[filename]; n++ {
+				filename = base + "-" + strconv.Itoa(n)
+			}
+			used[filename] = true
+
+			var b []byte
+			if format == "yaml" {
+				b, err = marshalYAML(entry.value)
+			} else {
+				var buf bytes.Buffer
+				err = writeIndentedJSON(&buf, entry.value)
+				b = buf.Bytes()
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(filepath.Join(path, filename+"."+format), b, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadPolicyFiles reads the JSON and YAML (.yaml, .yml) policy files written by
+// WritePolicyFiles. Policies are normalized, so files may still contain the
+// fields an export leaves out. Missing subdirectories are treated as empty.
+func LoadPolicyFiles(dir string) (*PolicySet, error) {
+
+	set := &PolicySet{}
+
+	load := func(subdir string, add func(filename string, b []byte) error) error {
+
+		filenames, err := policyFiles(filepath.Join(dir, subdir))
+		if err != nil {
+			return err
+		}
+
+		for _, filename := range filenames {
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			if err := add(filename, b); err != nil {
+				return fmt.Errorf("%s: %s", filename, err)
+			}
+		}
+
+		return nil
+	}
+
+	err := load(agingPolicyDir, func(filename string, b []byte) error {
+		var policy AgingPolicy
+		if err := unmarshalByExtension(filename, b, &policy); err != nil {
+			return err
+		}
+		if nonzero(policy.Name) {
+			return errors.New("AgingPolicy.Name is missing")
+		}
+		if err := normalizePolicy(policy, &policy); err != nil {
+			return err
+		}
+		set.AgingPolicies = append(set.AgingPolicies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = load(suspensionPolicyDir, func(filename string, b []byte) error {
+		var policy SuspensionPolicy
+		if err := unmarshalByExtension(filename, b, &policy); err != nil {
+			return err
+		}
+		if nonzero(policy.Name) {
+			return errors.New("SuspensionPolicy.Name is missing")
+		}
+		if err := normalizePolicy(policy, &policy); err != nil {
+			return err
+		}
+		set.SuspensionPolicies = append(set.SuspensionPolicies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = load(actionPolicyDir, func(filename string, b []byte) error {
+		var policy ActionPolicy
+		if err := unmarshalByExtension(filename, b, &policy); err != nil {
+			return err
+		}
+		if nonzero(policy.Name) {
+			return errors.New("ActionPolicy.Name is missing")
+		}
+		if err := normalizePolicy(policy, &policy); err != nil {
+			return err
+		}
+		set.ActionPolicies = append(set.ActionPolicies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	set.sort()
+
+	return set, nil
+}
+
+// PlanPolicySet compares the set with the live policies, by kind and name,
+// and returns the creates and updates needed to reach it, and with prune the
+// deletes of live policies missing from the set. Only the fields set in a
+// policy are compared and updated. An empty plan means nothing differs.
+func (s *Client) PlanPolicySet(ctx context.Context, set *PolicySet, prune bool) (*PolicyPlan, error) {
+
+	kinds, err := s.policyKinds(ctx, set)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &PolicyPlan{}
+
+	for _, kind := range kinds {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		live := make(map[string]policyEntry)
+		for _, entry := range kind.live {
+			if _, ok := live[entry.name]; ok {
+				return nil, errors.New("More than one " + kind.kind + " is named " + entry.name + " in CloudCenter")
+			}
+			live[entry.name] = entry
+		}
+
+		desired := make(map[string]bool)
+
+		for _, entry := range kind.desired {
+
+			if desired[entry.name] {
+				return nil, errors.New("More than one " + kind.kind + " is named " + entry.name)
+			}
+			desired[entry.name] = true
+
+			kind, entry := kind, entry
+
+			current, ok := live[entry.name]
+			if !ok {
+				plan.Steps = append(plan.Steps, PolicyPlanStep{
+					Action: PlanCreate,
+					Kind:   kind.kind,
+					Name:   entry.name,
+					Fields: desiredFieldChanges(struct{}{}, entry.value, policyIgnoredFields),
+					run: func(s *Client) error {
+						return kind.add(s, entry.value)
+					},
+				})
+				continue
+			}
+
+			if fields := desiredFieldChanges(current.value, entry.value, policyIgnoredFields); len(fields) > 0 {
+				plan.Steps = append(plan.Steps, PolicyPlanStep{
+					Action: PlanUpdate,
+					Kind:   kind.kind,
+					Name:   entry.name,
+					Fields: fields,
+					run: func(s *Client) error {
+						return kind.update(s, current.value, entry.value)
+					},
+				})
+			}
+		}
+
+		if !prune {
+			continue
+		}
+
+		for _, entry := range kind.live {
+
+			if desired[entry.name] {
+				continue
+			}
+
+			kind, entry := kind, entry
+
+			plan.Steps = append(plan.Steps, PolicyPlanStep{
+				Action: PlanDelete,
+				Kind:   kind.kind,
+				Name:   entry.name,
+				run: func(s *Client) error {
+					id, err := strconv.Atoi(entry.id)
+					if err != nil {
+						return err
+					}
+					return kind.remove(id)
+				},
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyPolicySet plans the set with PlanPolicySet, writes the plan to
+// opts.Output and applies it step by step, stopping at the first failure.
+func (s *Client) ApplyPolicySet(ctx context.Context, set *PolicySet, opts PolicyApplyOptions) (*PolicyPlan, error) {
+
+	plan, err := s.PlanPolicySet(ctx, set, opts.Prune)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Output != nil {
+		if err := plan.WriteText(opts.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.PlanOnly {
+		return plan, nil
+	}
+
+	for _, step := range plan.Steps {
+
+		if err := ctx.Err(); err != nil {
+			return plan, err
+		}
+
+		if err := step.run(s); err != nil {
+			return plan, fmt.Errorf("%s %s %s failed: %s", step.Action, step.Kind, step.Name, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// ImportPolicies loads the policy files in dir and applies them with
+// ApplyPolicySet.
+func (s *Client) ImportPolicies(ctx context.Context, dir string, opts PolicyApplyOptions) (*PolicyPlan, error) {
+
+	set, err := LoadPolicyFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ApplyPolicySet(ctx, set, opts)
+}
+
+func (p *PolicyPlan) HasChanges() bool {
+	return len(p.Steps) > 0
+}
+
+func (p *PolicyPlan) WriteText(w io.Writer) error {
+
+	if !p.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+
+	symbols := map[string]string{
+		PlanCreate: "+",
+		PlanUpdate: "~",
+		PlanDelete: "-",
+	}
+
+	for _, step := range p.Steps {
+
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", symbols[step.Action], step.Kind, step.Name); err != nil {
+			return err
+		}
+
+		for _, field := range step.Fields {
+			if _, err := fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, formatFieldValue(field.Old), formatFieldValue(field.New)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *PolicyPlan) String() string {
+	var b strings.Builder
+	p.WriteText(&b)
+	return b.String()
+}
+
+// policyKinds pairs the live policies of each kind with those of the set.
+func (s *Client) policyKinds(ctx context.Context, set *PolicySet) ([]policyKind, error) {
+
+	agingPolicies, suspensionPolicies, actionPolicies, err := s.getPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aging := policyKind{
+		kind: "agingPolicy",
+		add: func(s *Client, desired interface{}) error {
+			policy := desired.(AgingPolicy)
+			_, err := s.AddAgingPolicy(&policy)
+			return err
+		},
+		update: func(s *Client, live interface{}, desired interface{}) error {
+			var policy AgingPolicy
+			if err := mergeDesired(live, desired, &policy, policyIgnoredFields); err != nil {
+				return err
+			}
+			_, err := s.UpdateAgingPolicy(&policy)
+			return err
+		},
+		remove: s.DeleteAgingPolicy,
+	}
+
+	for _, policy := range agingPolicies {
+		aging.live = append(aging.live, policyEntry{stringValue(policy.Name), stringValue(policy.Id), policy})
+	}
+	for _, policy := range set.AgingPolicies {
+		aging.desired = append(aging.desired, policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+
+	suspension := policyKind{
+		kind: "suspensionPolicy",
+		add: func(s *Client, desired interface{}) error {
+			policy := desired.(SuspensionPolicy)
+			_, err := s.AddSuspensionPolicy(&policy)
+			return err
+		},
+		update: func(s *Client, live interface{}, desired interface{}) error {
+			var policy SuspensionPolicy
+			if err := mergeDesired(live, desired, &policy, policyIgnoredFields); err != nil {
+				return err
+			}
+			_, err := s.UpdateSuspensionPolicy(&policy)
+			return err
+		},
+		remove: s.DeleteSuspensionPolicy,
+	}
+
+	for _, policy := range suspensionPolicies {
+		suspension.live = append(suspension.live, policyEntry{stringValue(policy.Name), stringValue(policy.Id), policy})
+	}
+	for _, policy := range set.SuspensionPolicies {
+		suspension.desired = append(suspension.desired, policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+
+	action := policyKind{
+		kind: "actionPolicy",
+		add: func(s *Client, desired interface{}) error {
+			policy := desired.(ActionPolicy)
+			_, err := s.AddActionPolicy(&policy)
+			return err
+		},
+		update: func(s *Client, live interface{}, desired interface{}) error {
+			var policy ActionPolicy
+			if err := mergeDesired(live, desired, &policy, policyIgnoredFields); err != nil {
+				return err
+			}
+			_, err := s.UpdateActionPolicy(&policy)
+			return err
+		},
+		remove: s.DeleteActionPolicy,
+	}
+
+	for _, policy := range actionPolicies {
+		action.live = append(action.live, policyEntry{stringValue(policy.Name), stringValue(policy.Id), policy})
+	}
+	for _, policy := range set.ActionPolicies {
+		action.desired = append(action.desired, policyEntry{name: stringValue(policy.Name), value: policy})
+	}
+
+	return []policyKind{aging, suspension, action}, nil
+}
+
+// getPolicies lists the aging, suspension and action policies and fetches each
+// of them by id, as the lists do not include every field of a policy.
+func (s *Client) getPolicies(ctx context.Context) ([]AgingPolicy, []SuspensionPolicy, []ActionPolicy, error) {
+
+	agingPolicies, err := s.GetAgingPolicies()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	suspensionPolicies, err := s.GetSuspensionPolicies()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	actionPolicies, err := s.GetActionPolicies()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	count := len(agingPolicies) + len(suspensionPolicies) + len(actionPolicies)
+
+	err = forEachConcurrently(ctx, count, defaultConcurrency, func(ctx context.Context, i int) error {
+
+		if i < len(agingPolicies) {
+			policyId, err := strconv.Atoi(stringValue(agingPolicies[i].Id))
+			if err != nil {
+				return err
+			}
+			policy, err := s.GetAgingPolicy(policyId)
+			if err != nil {
+				return err
+			}
+			agingPolicies[i] = *policy
+			return nil
+		}
+
+		i -= len(agingPolicies)
+
+		if i < len(suspensionPolicies) {
+			policyId, err := strconv.Atoi(stringValue(suspensionPolicies[i].Id))
+			if err != nil {
+				return err
+			}
+			policy, err := s.GetSuspensionPolicy(policyId)
+			if err != nil {
+				return err
+			}
+			suspensionPolicies[i] = *policy
+			return nil
+		}
+
+		i -= len(suspensionPolicies)
+
+		policyId, err := strconv.Atoi(stringValue(actionPolicies[i].Id))
+		if err != nil {
+			return err
+		}
+		policy, err := s.GetActionPolicy(policyId)
+		if err != nil {
+			return err
+		}
+		actionPolicies[i] = *policy
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return agingPolicies, suspensionPolicies, actionPolicies, nil
+}
+
+func (set *PolicySet) sort() {
+
+	sort.SliceStable(set.AgingPolicies, func(i, j int) bool {
+		return stringValue(set.AgingPolicies[i].Name) < stringValue(set.AgingPolicies[j].Name)
+	})
+	sort.SliceStable(set.SuspensionPolicies, func(i, j int) bool {
+		return stringValue(set.SuspensionPolicies[i].Name) < stringValue(set.SuspensionPolicies[j].Name)
+	})
+	sort.SliceStable(set.ActionPolicies, func(i, j int) bool {
+		return stringValue(set.ActionPolicies[i].Name) < stringValue(set.ActionPolicies[j].Name)
+	})
+}
+
+// normalizePolicy copies policy into out without policyIgnoredFields.
+func normalizePolicy(policy interface{}, out interface{}) error {
+
+	m := toJSONMap(policy)
+
+	for field := range policyIgnoredFields {
+		delete(m, field)
+	}
+
+	j, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// out may be the policy itself, so clear it before decoding
+	v := reflect.ValueOf(out).Elem()
+	v.Set(reflect.Zero(v.Type()))
+
+	return json.Unmarshal(j, out)
+}
+
+// policyFiles returns the JSON and YAML files in dir, sorted. A missing dir has
+// no files.
+func policyFiles(dir string) ([]string, error) {
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var filenames []string
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if isYAMLFile(info.Name()) || strings.HasSuffix(strings.ToLower(info.Name()), ".json") {
+			filenames = append(filenames, filepath.Join(dir, info.Name()))
+		}
+	}
+
+	return filenames, nil
+}
+
+// policyFileName turns a policy name into a file name without extension:
+// lowercase letters, digits and dashes.
+func policyFileName(name string) string {
+
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	filename := strings.TrimSuffix(b.String(), "-")
+	if filename == "" {
+		filename = "policy"
+	}
+
+	return filename
+}
@@ -0,0 +1,598 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	PriceSheetInstance = "instance"
+	PriceSheetStorage  = "storage"
+)
+
+// PriceSheetClear is the price cell value that removes a price.
+const PriceSheetClear = "none"
+
+// priceSheetColumns lists the price columns each kind of row may set.
+var priceSheetColumns = map[string][]string{
+	PriceSheetInstance: {"costPerHour"},
+	PriceSheetStorage:  {"costPerMonth", "iopsCostPerMonth"},
+}
+
+// PriceSheetRow is one row of a price sheet. Kind is "instance" or "storage";
+// when the sheet has no kind column it is derived from the prices present.
+// Cloud is only needed when a region name exists in more than one cloud. Nil
+// prices are left unchanged, and the price columns named in Clear are removed.
+type PriceSheetRow struct {
+	Line             int      `json:"line"`
+	Kind             string   `json:"kind"`
+	Cloud            string   `json:"cloud,omitempty"`
+	Region           string   `json:"region"`
+	Name             string   `json:"name"`
+	CostPerHour      *float64 `json:"costPerHour,omitempty"`
+	CostPerMonth     *float64 `json:"costPerMonth,omitempty"`
+	IOPSCostPerMonth *float64 `json:"iopsCostPerMonth,omitempty"`
+	Clear            []string `json:"clear,omitempty"`
+}
+
+// PriceChange is the update of one instance or storage type's prices.
+type PriceChange struct {
+	Kind   string        `json:"kind"`
+	Cloud  string        `json:"cloud"`
+	Region string        `json:"region"`
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+
+	instanceType *CloudInstanceType
+	storageType  *CloudStorageType
+	cleared      []string
+	undo         PriceSheetRow
+}
+
+// PriceSheetIssue is a row that could not be matched to a single type.
+type PriceSheetIssue struct {
+	Row    PriceSheetRow `json:"row"`
+	Reason string        `json:"reason"`
+}
+
+type PriceChangePlan struct {
+	TenantId  int               `json:"tenantId"`
+	Changes   []PriceChange     `json:"changes"`
+	Unmatched []PriceSheetIssue `json:"unmatched,omitempty"`
+}
+
+type PriceSheetApplyOptions struct {
+
+	// UndoFile, when set, receives a price sheet with the old prices before
+	// anything is changed. Importing it restores those prices.
+	UndoFile string
+
+	// Concurrency bounds the number of parallel updates; zero uses the
+	// library default.
+	Concurrency int
+
+	// Output, when set, receives one line per applied change.
+	Output io.Writer
+}
+
+// LoadPriceSheet reads a CSV price sheet from filename.
+func LoadPriceSheet(filename string) ([]PriceSheetRow, error) {
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadPriceSheet(f)
+}
+
+// ReadPriceSheet reads a CSV price sheet. The first row is a header naming the
+// columns region, name and any of kind, cloud, costPerHour, costPerMonth and
+// iopsCostPerMonth, in any order and case. Empty price cells are left unchanged
+// and cells holding PriceSheetClear remove the price.
+func ReadPriceSheet(r io.Reader) ([]PriceSheetRow, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("Price sheet is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"region", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.New("Price sheet has no " + required + " column")
+		}
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := columns[strings.ToLower(column)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []PriceSheetRow
+
+	for n, record := range records[1:] {
+
+		row := PriceSheetRow{
+			Line:   n + 2,
+			Kind:   strings.ToLower(cell(record, "kind")),
+			Cloud:  cell(record, "cloud"),
+			Region: cell(record, "region"),
+			Name:   cell(record, "name"),
+		}
+
+		if row.Region == "" && row.Name == "" {
+			continue
+		}
+
+		prices := []struct {
+			column string
+			value  **float64
+		}{
+			{"costPerHour", &row.CostPerHour},
+			{"costPerMonth", &row.CostPerMonth},
+			{"iopsCostPerMonth", &row.IOPSCostPerMonth},
+		}
+
+		for _, price := range prices {
+			value := cell(record, price.column)
+			if value == "" {
+				continue
+			}
+			if strings.EqualFold(value, PriceSheetClear) {
+				row.Clear = append(row.Clear, price.column)
+				continue
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Price sheet line %d: invalid %s %q", row.Line, price.column, value)
+			}
+			*price.value = &f
+		}
+
+		if row.Kind == "" {
+			if row.hasPrice("costPerHour") {
+				row.Kind = PriceSheetInstance
+			} else {
+				row.Kind = PriceSheetStorage
+			}
+		}
+
+		if err := row.validate(); err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// validate checks that the row's kind is known and that it only sets or clears
+// the prices of that kind.
+func (row *PriceSheetRow) validate() error {
+
+	columns, ok := priceSheetColumns[row.Kind]
+	if !ok {
+		return fmt.Errorf("Price sheet line %d: unknown kind %q", row.Line, row.Kind)
+	}
+
+	for _, column := range row.Clear {
+		if !containsString(priceSheetColumns[PriceSheetInstance], column) && !containsString(priceSheetColumns[PriceSheetStorage], column) {
+			return fmt.Errorf("Price sheet line %d: unknown price column %q", row.Line, column)
+		}
+	}
+
+	for _, column := range []string{"costPerHour", "costPerMonth", "iopsCostPerMonth"} {
+		if row.hasPrice(column) && !containsString(columns, column) {
+			return fmt.Errorf("Price sheet line %d: %s does not apply to %s types", row.Line, column, row.Kind)
+		}
+	}
+
+	return nil
+}
+
+// hasPrice reports whether the row sets or clears the price in column.
+func (row *PriceSheetRow) hasPrice(column string) bool {
+
+	if containsString(row.Clear, column) {
+		return true
+	}
+
+	switch column {
+	case "costPerHour":
+		return row.CostPerHour != nil
+	case "costPerMonth":
+		return row.CostPerMonth != nil
+	case "iopsCostPerMonth":
+		return row.IOPSCostPerMonth != nil
+	}
+
+	return false
+}
+
+// priceCell formats the row's price in column for a price sheet.
+func (row *PriceSheetRow) priceCell(column string, price *float64) string {
+
+	if containsString(row.Clear, column) {
+		return PriceSheetClear
+	}
+
+	return formatPrice(price)
+}
+
+// WritePriceSheet writes rows as a CSV price sheet that ReadPriceSheet accepts.
+func WritePriceSheet(w io.Writer, rows []PriceSheetRow) error {
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"kind", "cloud", "region", "name", "costPerHour", "costPerMonth", "iopsCostPerMonth"}); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		err := writer.Write([]string{
+			row.Kind,
+			row.Cloud,
+			row.Region,
+			row.Name,
+			row.priceCell("costPerHour", row.CostPerHour),
+			row.priceCell("costPerMonth", row.CostPerMonth),
+			row.priceCell("iopsCostPerMonth", row.IOPSCostPerMonth),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// PlanPriceSheet matches the rows to the tenant's instance and storage types by
+// kind, region and name (and cloud when given) and returns the price changes.
+// Rows whose prices already match produce no change, and a row setting a price
+// its kind does not have is an error.
+func (s *Client) PlanPriceSheet(ctx context.Context, tenantId int, rows []PriceSheetRow) (*PriceChangePlan, error) {
+
+	for i := range rows {
+		if err := rows[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	topology, err := s.SnapshotCloudTopology(ctx, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &PriceChangePlan{TenantId: tenantId}
+
+	for _, row := range rows {
+
+		var matches []PriceChange
+
+		for _, cloud := range topology.Clouds {
+
+			cloudName := stringValue(cloud.Cloud.Name)
+			if row.Cloud != "" && !strings.EqualFold(row.Cloud, cloudName) {
+				continue
+			}
+
+			for _, region := range cloud.Regions {
+
+				regionName := regionKey(region.Region)
+				if !strings.EqualFold(row.Region, regionName) {
+					continue
+				}
+
+				scope := func(tenantId, cloudId, regionId **string) {
+					*tenantId = String(topology.TenantId)
+					*cloudId = cloud.Cloud.Id
+					*regionId = region.Region.Id
+				}
+
+				if row.Kind == PriceSheetInstance {
+					for i := range region.InstanceTypes {
+						if stringValue(region.InstanceTypes[i].Name) == row.Name {
+							instanceType := region.InstanceTypes[i]
+							scope(&instanceType.TenantId, &instanceType.CloudId, &instanceType.RegionId)
+							matches = append(matches, PriceChange{instanceType: &instanceType, Cloud: cloudName, Region: regionName})
+						}
+					}
+				} else {
+					for i := range region.StorageTypes {
+						if stringValue(region.StorageTypes[i].Name) == row.Name {
+							storageType := region.StorageTypes[i]
+							scope(&storageType.TenantId, &storageType.CloudId, &storageType.RegionId)
+							matches = append(matches, PriceChange{storageType: &storageType, Cloud: cloudName, Region: regionName})
+						}
+					}
+				}
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			plan.Unmatched = append(plan.Unmatched, PriceSheetIssue{Row: row, Reason: "no " + row.Kind + " type " + row.Name + " in region " + row.Region})
+			continue
+		case 1:
+		default:
+			plan.Unmatched = append(plan.Unmatched, PriceSheetIssue{Row: row, Reason: "region " + row.Region + " exists in several clouds, add a cloud column"})
+			continue
+		}
+
+		change := matches[0]
+		change.Kind = row.Kind
+		change.Name = row.Name
+		change.undo = PriceSheetRow{Kind: row.Kind, Cloud: change.Cloud, Region: change.Region, Name: row.Name}
+
+		if change.instanceType != nil {
+			change.setPrice("costPerHour", &change.instanceType.CostPerHour, row, row.CostPerHour, &change.undo.CostPerHour)
+		} else {
+			change.setPrice("costPerMonth", &change.storageType.CostPerMonth, row, row.CostPerMonth, &change.undo.CostPerMonth)
+			change.setPrice("iopsCostPerMonth", &change.storageType.IOPSCostPerMonth, row, row.IOPSCostPerMonth, &change.undo.IOPSCostPerMonth)
+		}
+
+		if len(change.Fields) > 0 {
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	return plan, nil
+}
+
+// setPrice records and applies the row's change of a price, keeping the old
+// price for the undo sheet. A price that was unset is cleared by the undo sheet.
+func (c *PriceChange) setPrice(field string, current **float64, row PriceSheetRow, price *float64, undo **float64) {
+
+	if containsString(row.Clear, field) {
+		if *current == nil {
+			return
+		}
+		c.Fields = append(c.Fields, FieldChange{Field: field, Old: **current, New: nil})
+		c.cleared = append(c.cleared, field)
+		*undo = *current
+		*current = nil
+		return
+	}
+
+	if price == nil || (*current != nil && **current == *price) {
+		return
+	}
+
+	c.Fields = append(c.Fields, FieldChange{Field: field, Old: priceValue(*current), New: *price})
+
+	if *current == nil {
+		c.undo.Clear = append(c.undo.Clear, field)
+	}
+
+	*undo = *current
+	*current = Float64(*price)
+}
+
+// ApplyPriceChanges writes the undo file, if requested, and then updates the
+// changed types concurrently. It stops at the first failed update.
+func (s *Client) ApplyPriceChanges(ctx context.Context, plan *PriceChangePlan, opts PriceSheetApplyOptions) error {
+
+	if opts.UndoFile != "" {
+		if err := writePriceSheetFile(opts.UndoFile, plan.UndoRows()); err != nil {
+			return err
+		}
+	}
+
+	var outputMu sync.Mutex
+
+	return forEachConcurrently(ctx, len(plan.Changes), opts.Concurrency, func(ctx context.Context, i int) error {
+
+		change := plan.Changes[i]
+
+		var err error
+		if len(change.cleared) > 0 {
+			err = s.updateClearingPrices(&change)
+		} else if change.instanceType != nil {
+			_, err = s.UpdateCloudInstanceType(change.instanceType)
+		} else {
+			_, err = s.UpdateCloudStorageType(change.storageType)
+		}
+
+		if err != nil {
+			return fmt.Errorf("Updating %s type %s in %s/%s failed: %s", change.Kind, change.Name, change.Cloud, change.Region, err)
+		}
+
+		if opts.Output != nil {
+			outputMu.Lock()
+			fmt.Fprintf(opts.Output, "updated %s type %s in %s/%s\n", change.Kind, change.Name, change.Cloud, change.Region)
+			outputMu.Unlock()
+		}
+
+		return nil
+	})
+}
+
+// ImportPriceSheet reads a price sheet, plans it and applies it.
+func (s *Client) ImportPriceSheet(ctx context.Context, tenantId int, filename string, opts PriceSheetApplyOptions) (*PriceChangePlan, error) {
+
+	rows, err := LoadPriceSheet(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := s.PlanPriceSheet(ctx, tenantId, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, s.ApplyPriceChanges(ctx, plan, opts)
+}
+
+// updateClearingPrices updates the change's type with its cleared prices sent
+// as null, which the typed updates would omit and so leave unchanged.
+func (s *Client) updateClearingPrices(change *PriceChange) error {
+
+	var v interface{}
+	var tenantId, cloudId, regionId, id *string
+	var collection string
+
+	if change.instanceType != nil {
+		v = change.instanceType
+		tenantId, cloudId, regionId, id = change.instanceType.TenantId, change.instanceType.CloudId, change.instanceType.RegionId, change.instanceType.Id
+		collection = "/instanceTypes/"
+	} else {
+		v = change.storageType
+		tenantId, cloudId, regionId, id = change.storageType.TenantId, change.storageType.CloudId, change.storageType.RegionId, change.storageType.Id
+		collection = "/storageTypes/"
+	}
+
+	if nonzero(id) {
+		return errors.New("Price change type Id is missing")
+	}
+
+	body := toJSONMap(v)
+	for _, field := range change.cleared {
+		body[field] = nil
+	}
+
+	url := fmt.Sprintf(s.BaseURL + "/v1/tenants/" + stringValue(tenantId) + "/clouds/" + stringValue(cloudId) + "/regions/" + stringValue(regionId) + collection + *id)
+
+	j, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(j))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.doRequest(req)
+
+	return err
+}
+
+// UndoRows returns a price sheet holding the prices the plan replaces.
+func (p *PriceChangePlan) UndoRows() []PriceSheetRow {
+
+	rows := make([]PriceSheetRow, 0, len(p.Changes))
+
+	for _, change := range p.Changes {
+		rows = append(rows, change.undo)
+	}
+
+	return rows
+}
+
+// WriteText writes the price change preview, followed by the unmatched rows.
+func (p *PriceChangePlan) WriteText(w io.Writer) error {
+
+	if len(p.Changes) == 0 {
+		if _, err := fmt.Fprintln(w, "No price changes"); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range p.Changes {
+		if _, err := fmt.Fprintf(w, "~ %s %s/%s/%s\n", change.Kind, change.Cloud, change.Region, change.Name); err != nil {
+			return err
+		}
+		for _, field := range change.Fields {
+			if _, err := fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, formatFieldValue(field.Old), formatFieldValue(field.New)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, issue := range p.Unmatched {
+		if _, err := fmt.Fprintf(w, "! line %d: %s\n", issue.Row.Line, issue.Reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writePriceSheetFile(filename string, rows []PriceSheetRow) error {
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := WritePriceSheet(f, rows); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func priceValue(price *float64) interface{} {
+
+	if price == nil {
+		return nil
+	}
+
+	return *price
+}
+
+func formatPrice(price *float64) string {
+
+	if price == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*price, 'f', -1, 64)
+}
+
+func containsString(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPriceSheet(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		sheet string
+		want  []PriceSheetRow
+	}{
+		{
+			name:  "instance price",
+			sheet: "Region,NAME,CostPerHour\nus-east-1,m4.large,0.1\n",
+			want:  []PriceSheetRow{{Line: 2, Kind: PriceSheetInstance, Region: "us-east-1", Name: "m4.large", CostPerHour: Float64(0.1)}},
+		},
+		{
+			name:  "storage kind derived from prices",
+			sheet: "region,name,costPerMonth,iopsCostPerMonth\nus-east-1,gp2,0.1,0.065\n",
+			want:  []PriceSheetRow{{Line: 2, Kind: PriceSheetStorage, Region: "us-east-1", Name: "gp2", CostPerMonth: Float64(0.1), IOPSCostPerMonth: Float64(0.065)}},
+		},
+		{
+			name:  "explicit kind and cloud",
+			sheet: "kind,cloud,region,name,costPerMonth\nSTORAGE,AWS,us-east-1,gp2,0.1\n",
+			want:  []PriceSheetRow{{Line: 2, Kind: PriceSheetStorage, Cloud: "AWS", Region: "us-east-1", Name: "gp2", CostPerMonth: Float64(0.1)}},
+		},
+		{
+			name:  "cleared prices keep column order",
+			sheet: "kind,region,name,iopsCostPerMonth,costPerMonth\nstorage,us-east-1,gp2,none,NONE\n",
+			want:  []PriceSheetRow{{Line: 2, Kind: PriceSheetStorage, Region: "us-east-1", Name: "gp2", Clear: []string{"costPerMonth", "iopsCostPerMonth"}}},
+		},
+		{
+			name:  "empty price cells and blank rows",
+			sheet: "region, name, costPerHour, costPerMonth\n,,,\nus-east-1, m4.large, 0.1,\n",
+			want:  []PriceSheetRow{{Line: 3, Kind: PriceSheetInstance, Region: "us-east-1", Name: "m4.large", CostPerHour: Float64(0.1)}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			rows, err := ReadPriceSheet(strings.NewReader(test.sheet))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Errorf("rows = %+v, want %+v", rows, test.want)
+			}
+		})
+	}
+}
+
+func TestReadPriceSheetErrors(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		sheet string
+		want  string
+	}{
+		{"empty", "", "empty"},
+		{"no region column", "name,costPerHour\nm4.large,0.1\n", "no region column"},
+		{"no name column", "region,costPerHour\nus-east-1,0.1\n", "no name column"},
+		{"invalid price", "region,name,costPerHour\nus-east-1,m4.large,cheap\n", "line 2: invalid costPerHour"},
+		{"unknown kind", "kind,region,name,costPerHour\nnetwork,us-east-1,m4.large,0.1\n", "unknown kind"},
+		{"price of the other kind", "kind,region,name,costPerMonth\ninstance,us-east-1,m4.large,0.1\n", "costPerMonth does not apply to instance types"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			_, err := ReadPriceSheet(strings.NewReader(test.sheet))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error = %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestWritePriceSheetRoundTrip(t *testing.T) {
+
+	rows := []PriceSheetRow{
+		{Line: 2, Kind: PriceSheetInstance, Cloud: "AWS", Region: "us-east-1", Name: "m4.large", CostPerHour: Float64(0.1)},
+		{Line: 3, Kind: PriceSheetInstance, Region: "us-east-1", Name: "m4.xlarge", Clear: []string{"costPerHour"}},
+		{Line: 4, Kind: PriceSheetStorage, Region: "us-east-1", Name: "gp2", CostPerMonth: Float64(0.1), IOPSCostPerMonth: Float64(0.065)},
+	}
+
+	var b bytes.Buffer
+
+	if err := WritePriceSheet(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPriceSheet(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(read, rows) {
+		t.Errorf("read back %+v, want %+v", read, rows)
+	}
+}
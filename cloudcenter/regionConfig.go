@@ -0,0 +1,507 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// RegionConfig is the desired state of a cloud region. Only the fields set in
+// Region, InstanceTypes and ImageMappings are managed; anything left unset
+// keeps its live value. The region is matched by RegionName, instance types by
+// Name and image mappings by the name of their image, so a configuration
+// exported from one CCM can be applied to another.
+type RegionConfig struct {
+	TenantId      int                  `json:"tenantId"`
+	CloudId       int                  `json:"cloudId"`
+	Region        CloudRegion          `json:"region"`
+	InstanceTypes []CloudInstanceType  `json:"instanceTypes,omitempty"`
+	ImageMappings []RegionImageMapping `json:"imageMappings,omitempty"`
+
+	// Prune deletes instance types and image mappings that exist in the
+	// region but are not listed in the configuration.
+	Prune bool `json:"prune,omitempty"`
+}
+
+// RegionImageMapping is an image mapping identified by the name of its image.
+// When ImageName is empty, the image is looked up by ImageId instead.
+type RegionImageMapping struct {
+	ImageName string `json:"imageName,omitempty"`
+	CloudImageMapping
+}
+
+const (
+	PlanCreate = "CREATE"
+	PlanUpdate = "UPDATE"
+	PlanDelete = "DELETE"
+)
+
+type RegionPlanStep struct {
+	Action string        `json:"action"`
+	Kind   string        `json:"kind"`
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields,omitempty"`
+
+	run func(s *Client, state *regionApplyState) error
+}
+
+type RegionPlan struct {
+	Steps []RegionPlanStep `json:"steps"`
+
+	// regionId is the live region's id, empty when the plan creates it
+	regionId string
+}
+
+type RegionApplyOptions struct {
+
+	// PlanOnly computes and returns the plan without changing anything.
+	PlanOnly bool
+
+	// Output, when set, receives the plan before it is applied.
+	Output io.Writer
+}
+
+type regionApplyState struct {
+	tenantId string
+	cloudId  string
+	regionId string
+}
+
+// regionConfigIgnoredFields are never compared or sent from the desired state.
+var regionConfigIgnoredFields = map[string]bool{
+	"id":            true,
+	"resource":      true,
+	"perms":         true,
+	"tenantId":      true,
+	"cloudId":       true,
+	"regionId":      true,
+	"cloudRegionId": true,
+	"status":        true,
+	"statusDetail":  true,
+	"numUsers":      true,
+}
+
+// LoadRegionConfig reads a region configuration from a JSON or YAML (.yaml,
+// .yml) file.
+func LoadRegionConfig(filename string) (*RegionConfig, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config RegionConfig
+
+	err = unmarshalByExtension(filename, b, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// PlanRegionConfig compares the configuration with the live region and returns
+// the creates, updates and deletes needed to reach it. An empty plan means the
+// region already matches.
+func (s *Client) PlanRegionConfig(ctx context.Context, config *RegionConfig) (*RegionPlan, error) {
+
+	if nonzero(config.Region.RegionName) {
+		return nil, errors.New("RegionConfig.Region.RegionName is missing")
+	}
+
+	regionName := *config.Region.RegionName
+	plan := &RegionPlan{}
+
+	regions, err := s.GetCloudRegions(config.TenantId, config.CloudId)
+	if err != nil {
+		return nil, err
+	}
+
+	var live *CloudRegion
+	for i := range regions {
+		if stringValue(regions[i].RegionName) == regionName {
+			live = &regions[i]
+		}
+	}
+
+	var liveInstanceTypes []CloudInstanceType
+	var liveMappings []CloudImageMapping
+
+	if live == nil {
+
+		plan.Steps = append(plan.Steps, RegionPlanStep{
+			Action: PlanCreate,
+			Kind:   "region",
+			Name:   regionName,
+			run: func(s *Client, state *regionApplyState) error {
+				region := config.Region
+				region.TenantId = String(state.tenantId)
+				region.CloudId = String(state.cloudId)
+				if region.DisplayName == nil {
+					region.DisplayName = String(regionName)
+				}
+				newRegion, err := s.AddCloudRegion(&region)
+				if err != nil {
+					return err
+				}
+				state.regionId = stringValue(newRegion.Id)
+				return nil
+			},
+		})
+
+	} else {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		plan.regionId = stringValue(live.Id)
+
+		regionId, err := strconv.Atoi(plan.regionId)
+		if err != nil {
+			return nil, err
+		}
+
+		if fields := desiredFieldChanges(*live, config.Region, regionConfigIgnoredFields); len(fields) > 0 {
+			current := *live
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanUpdate,
+				Kind:   "region",
+				Name:   regionName,
+				Fields: fields,
+				run: func(s *Client, state *regionApplyState) error {
+					var region CloudRegion
+					if err := mergeDesired(current, config.Region, &region, regionConfigIgnoredFields); err != nil {
+						return err
+					}
+					region.TenantId = String(state.tenantId)
+					region.CloudId = String(state.cloudId)
+					_, err := s.UpdateCloudRegion(&region)
+					return err
+				},
+			})
+		}
+
+		liveInstanceTypes, err = s.GetCloudInstanceTypes(config.TenantId, config.CloudId, regionId)
+		if err != nil {
+			return nil, err
+		}
+
+		liveMappings, err = s.GetCloudImageMappings(config.TenantId, config.CloudId, regionId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	liveTypesByName := make(map[string]CloudInstanceType)
+	for _, instanceType := range liveInstanceTypes {
+		name := stringValue(instanceType.Name)
+		if _, ok := liveTypesByName[name]; ok {
+			return nil, errors.New("More than one instance type is named " + name)
+		}
+		liveTypesByName[name] = instanceType
+	}
+
+	wantedTypes := make(map[string]bool)
+
+	for _, desired := range config.InstanceTypes {
+
+		desired := desired
+		name := stringValue(desired.Name)
+		if name == "" {
+			return nil, errors.New("RegionConfig.InstanceTypes: Name is missing")
+		}
+		if wantedTypes[name] {
+			return nil, errors.New("RegionConfig.InstanceTypes: more than one is named " + name)
+		}
+		wantedTypes[name] = true
+
+		current, ok := liveTypesByName[name]
+		if !ok {
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanCreate,
+				Kind:   "instanceType",
+				Name:   name,
+				run: func(s *Client, state *regionApplyState) error {
+					instanceType := desired
+					instanceType.TenantId = String(state.tenantId)
+					instanceType.CloudId = String(state.cloudId)
+					instanceType.RegionId = String(state.regionId)
+					_, err := s.AddCloudInstanceType(&instanceType)
+					return err
+				},
+			})
+			continue
+		}
+
+		if fields := desiredFieldChanges(current, desired, regionConfigIgnoredFields); len(fields) > 0 {
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanUpdate,
+				Kind:   "instanceType",
+				Name:   name,
+				Fields: fields,
+				run: func(s *Client, state *regionApplyState) error {
+					var instanceType CloudInstanceType
+					if err := mergeDesired(current, desired, &instanceType, regionConfigIgnoredFields); err != nil {
+						return err
+					}
+					instanceType.TenantId = String(state.tenantId)
+					instanceType.CloudId = String(state.cloudId)
+					instanceType.RegionId = String(state.regionId)
+					_, err := s.UpdateCloudInstanceType(&instanceType)
+					return err
+				},
+			})
+		}
+	}
+
+	var images []Image
+	if len(config.ImageMappings) > 0 || config.Prune && len(liveMappings) > 0 {
+		images, err = s.GetImages(config.TenantId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imageIds := make(map[string]string)
+	imageNames := make(map[string]string)
+	for _, image := range images {
+		name := stringValue(image.Name)
+		if _, ok := imageIds[name]; ok {
+			return nil, errors.New("More than one image is named " + name)
+		}
+		imageIds[name] = stringValue(image.Id)
+		imageNames[stringValue(image.Id)] = name
+	}
+
+	liveMappingsByImage := make(map[string]CloudImageMapping)
+	for _, mapping := range liveMappings {
+		imageName, ok := imageNames[stringValue(mapping.ImageId)]
+		if !ok {
+			imageName = "image-" + stringValue(mapping.ImageId)
+		}
+		liveMappingsByImage[imageName] = mapping
+	}
+
+	wantedMappings := make(map[string]bool)
+
+	for _, entry := range config.ImageMappings {
+
+		imageName := entry.ImageName
+		if imageName == "" {
+			imageName = imageNames[stringValue(entry.ImageId)]
+		}
+		if imageName == "" {
+			return nil, errors.New("RegionConfig.ImageMappings: ImageName is missing")
+		}
+
+		imageId, ok := imageIds[imageName]
+		if !ok {
+			return nil, errors.New("RegionConfig.ImageMappings: no image is named " + imageName)
+		}
+
+		if wantedMappings[imageName] {
+			return nil, errors.New("RegionConfig.ImageMappings: more than one maps image " + imageName)
+		}
+		wantedMappings[imageName] = true
+
+		// the image is identified by name; its id is the one of this CCM
+		desired := entry.CloudImageMapping
+		desired.ImageId = nil
+
+		current, ok := liveMappingsByImage[imageName]
+		if !ok {
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanCreate,
+				Kind:   "imageMapping",
+				Name:   imageName,
+				run: func(s *Client, state *regionApplyState) error {
+					mapping := desired
+					mapping.ImageId = String(imageId)
+					mapping.TenantId = String(state.tenantId)
+					mapping.CloudId = String(state.cloudId)
+					mapping.RegionId = String(state.regionId)
+					mapping.CloudRegionId = String(state.regionId)
+					_, err := s.AddCloudImageMapping(&mapping)
+					return err
+				},
+			})
+			continue
+		}
+
+		if fields := desiredFieldChanges(current, desired, regionConfigIgnoredFields); len(fields) > 0 {
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanUpdate,
+				Kind:   "imageMapping",
+				Name:   imageName,
+				Fields: fields,
+				run: func(s *Client, state *regionApplyState) error {
+					var mapping CloudImageMapping
+					if err := mergeDesired(current, desired, &mapping, regionConfigIgnoredFields); err != nil {
+						return err
+					}
+					mapping.TenantId = String(state.tenantId)
+					mapping.CloudId = String(state.cloudId)
+					mapping.RegionId = String(state.regionId)
+					mapping.CloudRegionId = String(state.regionId)
+					_, err := s.UpdateCloudImageMapping(&mapping)
+					return err
+				},
+			})
+		}
+	}
+
+	if config.Prune {
+
+		for _, name := range unionKeys(liveTypesByName) {
+
+			if wantedTypes[name] {
+				continue
+			}
+
+			id, err := strconv.Atoi(stringValue(liveTypesByName[name].Id))
+			if err != nil {
+				return nil, err
+			}
+
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanDelete,
+				Kind:   "instanceType",
+				Name:   name,
+				run: func(s *Client, state *regionApplyState) error {
+					regionId, err := strconv.Atoi(state.regionId)
+					if err != nil {
+						return err
+					}
+					return s.DeleteCloudInstanceType(config.TenantId, config.CloudId, regionId, id)
+				},
+			})
+		}
+
+		for _, imageName := range unionKeys(liveMappingsByImage) {
+
+			if wantedMappings[imageName] {
+				continue
+			}
+
+			id, err := strconv.Atoi(stringValue(liveMappingsByImage[imageName].Id))
+			if err != nil {
+				return nil, err
+			}
+
+			plan.Steps = append(plan.Steps, RegionPlanStep{
+				Action: PlanDelete,
+				Kind:   "imageMapping",
+				Name:   imageName,
+				run: func(s *Client, state *regionApplyState) error {
+					regionId, err := strconv.Atoi(state.regionId)
+					if err != nil {
+						return err
+					}
+					return s.DeleteCloudImageMapping(config.TenantId, config.CloudId, regionId, id)
+				},
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplyRegionConfig brings the region to the configured state. Applying the
+// same configuration twice makes no further changes. With opts.PlanOnly set
+// the plan is returned (and written to opts.Output) without being applied.
+func (s *Client) ApplyRegionConfig(ctx context.Context, config *RegionConfig, opts RegionApplyOptions) (*RegionPlan, error) {
+
+	plan, err := s.PlanRegionConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Output != nil {
+		if err := plan.WriteText(opts.Output); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.PlanOnly {
+		return plan, nil
+	}
+
+	state := &regionApplyState{
+		tenantId: strconv.Itoa(config.TenantId),
+		cloudId:  strconv.Itoa(config.CloudId),
+		regionId: plan.regionId,
+	}
+
+	for _, step := range plan.Steps {
+
+		if err := ctx.Err(); err != nil {
+			return plan, err
+		}
+
+		if err := step.run(s, state); err != nil {
+			return plan, fmt.Errorf("%s %s %s failed: %s", step.Action, step.Kind, step.Name, err)
+		}
+	}
+
+	return plan, nil
+}
+
+func (p *RegionPlan) HasChanges() bool {
+	return len(p.Steps) > 0
+}
+
+func (p *RegionPlan) WriteText(w io.Writer) error {
+
+	if !p.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes")
+		return err
+	}
+
+	symbols := map[string]string{
+		PlanCreate: "+",
+		PlanUpdate: "~",
+		PlanDelete: "-",
+	}
+
+	for _, step := range p.Steps {
+
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", symbols[step.Action], step.Kind, step.Name); err != nil {
+			return err
+		}
+
+		for _, field := range step.Fields {
+			if _, err := fmt.Fprintf(w, "    %s: %s -> %s\n", field.Field, formatFieldValue(field.Old), formatFieldValue(field.New)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *RegionPlan) String() string {
+	var b strings.Builder
+	p.WriteText(&b)
+	return b.String()
+}
@@ -0,0 +1,46 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"errors"
+)
+
+const (
+	ResourceTypeDeployment     = "DEPLOYMENT"
+	ResourceTypeVirtualMachine = "VIRTUAL_MACHINE"
+)
+
+// ResourceTarget is a resource a policy is applied to or an action runs
+// against: a deployment, identified by its job id, or a virtual machine.
+type ResourceTarget struct {
+	ResourceId   string `json:"resourceId"`
+	ResourceType string `json:"resourceType"`
+}
+
+func (t ResourceTarget) validate() error {
+
+	if t.ResourceId == "" {
+		return errors.New("ResourceTarget.ResourceId is missing")
+	}
+
+	if t.ResourceType == "" {
+		return errors.New("ResourceTarget.ResourceType is missing")
+	}
+
+	return nil
+}
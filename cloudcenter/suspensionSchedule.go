@@ -0,0 +1,384 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// suspensionHorizon bounds how far ahead NextSuspend and NextResume look.
+const suspensionHorizon = 366 * 24 * time.Hour
+
+// SuspensionWindow is a period during which a policy suspends its resources.
+type SuspensionWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SuspensionEvaluator answers when a suspension policy suspends its resources,
+// without calling CloudCenter.
+type SuspensionEvaluator struct {
+	location  *time.Location
+	enabled   bool
+	rules     []suspensionRule
+	blockouts []SuspensionWindow
+	anchor    time.Time
+}
+
+type suspensionRule struct {
+	days        map[time.Weekday]bool
+	start       clockTime
+	end         clockTime
+	everyNWeeks int
+}
+
+type clockTime struct {
+	hour, minute, second int
+}
+
+// Schedule types. ScheduleTypeWeekly, also given as "1" or left empty,
+// suspends on the schedule's Days; ScheduleTypeDaily suspends on every day.
+const (
+	ScheduleTypeWeekly = "WEEKLY"
+	ScheduleTypeDaily  = "DAILY"
+)
+
+// repeatsWeeks maps the named Repeats values to a number of weeks.
+var repeatsWeeks = map[string]int{
+	"WEEKLY":   1,
+	"BIWEEKLY": 2,
+}
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// NewSuspensionEvaluator parses the policy's schedules and blockout periods.
+// Schedule times are wall-clock times in location (time.Local when nil).
+//
+// Each schedule suspends from StartTime to EndTime. A weekly schedule (Type
+// WEEKLY, "1" or empty) does so on each of its Days, or on every day when Days
+// is empty; a DAILY schedule does so on every day and must not list Days. Other
+// types are rejected. An EndTime at or before the StartTime ends on the
+// following day. Repeats is the number of weeks between occurrences, counted
+// from the week the policy was created, given as a number, WEEKLY or BIWEEKLY;
+// empty means every week and anything else is rejected. Blockout periods, given
+// in epoch milliseconds, are periods in which the policy does not suspend. A
+// disabled policy never suspends.
+func NewSuspensionEvaluator(policy *SuspensionPolicy, location *time.Location) (*SuspensionEvaluator, error) {
+
+	if location == nil {
+		location = time.Local
+	}
+
+	e := &SuspensionEvaluator{
+		location: location,
+		enabled:  policy.Enabled == nil || *policy.Enabled,
+	}
+
+	if policy.Created != nil {
+		e.anchor = epochMillis(*policy.Created).In(location)
+	}
+
+	if policy.Schedules != nil {
+		for i, schedule := range *policy.Schedules {
+			rule, err := parseSchedule(schedule)
+			if err != nil {
+				return nil, fmt.Errorf("Schedule %d: %s", i+1, err)
+			}
+			if rule.everyNWeeks > 1 && e.anchor.IsZero() {
+				return nil, fmt.Errorf("Schedule %d: repeats every %d weeks but the policy has no Created date", i+1, rule.everyNWeeks)
+			}
+			e.rules = append(e.rules, rule)
+		}
+	}
+
+	if policy.BlockoutPeriods != nil {
+		for i, blockout := range *policy.BlockoutPeriods {
+			if blockout.StartDate == nil || blockout.EndDate == nil {
+				return nil, fmt.Errorf("Blockout period %d: StartDate and EndDate are required", i+1)
+			}
+			e.blockouts = append(e.blockouts, SuspensionWindow{
+				Start: epochMillis(*blockout.StartDate).In(location),
+				End:   epochMillis(*blockout.EndDate).In(location),
+			})
+		}
+	}
+
+	return e, nil
+}
+
+// IsSuspended reports whether the policy suspends its resources at t.
+func (e *SuspensionEvaluator) IsSuspended(t time.Time) bool {
+
+	for _, window := range e.windows(t.Add(-48*time.Hour), t.Add(48*time.Hour)) {
+		if !t.Before(window.Start) && t.Before(window.End) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Windows returns the suspension windows overlapping [from, to), clipped to
+// that range, with blockout periods removed and adjacent windows merged.
+func (e *SuspensionEvaluator) Windows(from time.Time, to time.Time) []SuspensionWindow {
+
+	var clipped []SuspensionWindow
+
+	for _, window := range e.windows(from, to) {
+
+		if window.Start.Before(from) {
+			window.Start = from
+		}
+		if window.End.After(to) {
+			window.End = to
+		}
+
+		if window.Start.Before(window.End) {
+			clipped = append(clipped, window)
+		}
+	}
+
+	return clipped
+}
+
+// NextSuspend returns the next time after t at which resources are suspended.
+// It returns false when there is none within a year.
+func (e *SuspensionEvaluator) NextSuspend(t time.Time) (time.Time, bool) {
+
+	for _, window := range e.windows(t.Add(-48*time.Hour), t.Add(suspensionHorizon)) {
+		if window.Start.After(t) {
+			return window.Start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// NextResume returns the next time after t at which suspended resources are
+// resumed: the end of the current window when t is inside one, otherwise the
+// end of the next one. It returns false when there is no window within a year
+// or when the window runs on for more than a year, as a continuous schedule
+// does.
+func (e *SuspensionEvaluator) NextResume(t time.Time) (time.Time, bool) {
+
+	to := t.Add(suspensionHorizon)
+
+	for _, window := range e.windows(t.Add(-48*time.Hour), to) {
+		if window.End.After(t) {
+			// a window reaching the horizon may go on beyond it, so its
+			// end is not a resume time
+			if !window.End.Before(to) {
+				return time.Time{}, false
+			}
+			return window.End, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// windows returns the merged windows that overlap [from, to), minus blockouts,
+// without clipping them.
+func (e *SuspensionEvaluator) windows(from time.Time, to time.Time) []SuspensionWindow {
+
+	if !e.enabled || len(e.rules) == 0 {
+		return nil
+	}
+
+	var raw []SuspensionWindow
+
+	// start a day early so windows running past midnight are included
+	first := from.In(e.location).AddDate(0, 0, -1)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, e.location)
+
+	for !day.After(to) {
+
+		for _, rule := range e.rules {
+
+			if len(rule.days) > 0 && !rule.days[day.Weekday()] {
+				continue
+			}
+
+			if rule.everyNWeeks > 1 && weeksBetween(e.anchor, day)%rule.everyNWeeks != 0 {
+				continue
+			}
+
+			start := rule.start.on(day, 0)
+			end := rule.end.on(day, 0)
+			if !end.After(start) {
+				end = rule.end.on(day, 1)
+			}
+
+			if end.After(from) && start.Before(to) {
+				raw = append(raw, SuspensionWindow{Start: start, End: end})
+			}
+		}
+
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, e.location)
+	}
+
+	return subtractWindows(mergeWindows(raw), e.blockouts)
+}
+
+func (c clockTime) on(day time.Time, addDays int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+addDays, c.hour, c.minute, c.second, 0, day.Location())
+}
+
+func parseSchedule(schedule Schedule) (suspensionRule, error) {
+
+	rule := suspensionRule{days: make(map[time.Weekday]bool), everyNWeeks: 1}
+
+	switch scheduleType := strings.ToUpper(strings.TrimSpace(stringValue(schedule.Type))); scheduleType {
+	case "", "1", ScheduleTypeWeekly:
+	case ScheduleTypeDaily:
+		if schedule.Days != nil && len(*schedule.Days) > 0 {
+			return rule, errors.New("a DAILY schedule cannot list days")
+		}
+	default:
+		return rule, errors.New("unsupported schedule type " + strconv.Quote(scheduleType) + ", expected WEEKLY or DAILY")
+	}
+
+	if schedule.Days != nil {
+		for _, day := range *schedule.Days {
+			name := strings.ToUpper(strings.TrimSpace(day))
+			if len(name) > 3 {
+				name = name[:3]
+			}
+			weekday, ok := weekdays[name]
+			if !ok {
+				return rule, errors.New("unknown day " + day)
+			}
+			rule.days[weekday] = true
+		}
+	}
+
+	var err error
+
+	rule.start, err = parseClockTime(stringValue(schedule.StartTime))
+	if err != nil {
+		return rule, err
+	}
+
+	rule.end, err = parseClockTime(stringValue(schedule.EndTime))
+	if err != nil {
+		return rule, err
+	}
+
+	if repeats := strings.ToUpper(strings.TrimSpace(stringValue(schedule.Repeats))); repeats != "" {
+		if weeks, ok := repeatsWeeks[repeats]; ok {
+			rule.everyNWeeks = weeks
+		} else if rule.everyNWeeks, err = strconv.Atoi(repeats); err != nil || rule.everyNWeeks < 1 {
+			return rule, errors.New("unsupported repeats " + strconv.Quote(repeats) + ", expected a number of weeks, WEEKLY or BIWEEKLY")
+		}
+	}
+
+	return rule, nil
+}
+
+func parseClockTime(value string) (clockTime, error) {
+
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return clockTime{t.Hour(), t.Minute(), t.Second()}, nil
+		}
+	}
+
+	return clockTime{}, errors.New("invalid time " + strconv.Quote(value) + ", expected HH:MM")
+}
+
+// weeksBetween counts the Sunday-started weeks from the week of anchor to the
+// week of day.
+func weeksBetween(anchor time.Time, day time.Time) int {
+
+	startOfWeek := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 12, 0, 0, 0, t.Location())
+	}
+
+	days := int(startOfWeek(day.In(anchor.Location())).Sub(startOfWeek(anchor)).Hours()/24 + 0.5)
+	weeks := days / 7
+	if weeks < 0 {
+		weeks = -weeks
+	}
+
+	return weeks
+}
+
+func mergeWindows(windows []SuspensionWindow) []SuspensionWindow {
+
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+
+	var merged []SuspensionWindow
+
+	for _, window := range windows {
+		last := len(merged) - 1
+		if last >= 0 && !window.Start.After(merged[last].End) {
+			if window.End.After(merged[last].End) {
+				merged[last].End = window.End
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+
+	return merged
+}
+
+func subtractWindows(windows []SuspensionWindow, blockouts []SuspensionWindow) []SuspensionWindow {
+
+	for _, blockout := range blockouts {
+
+		var remaining []SuspensionWindow
+
+		for _, window := range windows {
+
+			if !blockout.Start.Before(window.End) || !blockout.End.After(window.Start) {
+				remaining = append(remaining, window)
+				continue
+			}
+
+			if window.Start.Before(blockout.Start) {
+				remaining = append(remaining, SuspensionWindow{Start: window.Start, End: blockout.Start})
+			}
+			if window.End.After(blockout.End) {
+				remaining = append(remaining, SuspensionWindow{Start: blockout.End, End: window.End})
+			}
+		}
+
+		windows = remaining
+	}
+
+	return windows
+}
+
+func epochMillis(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
@@ -0,0 +1,217 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"testing"
+	"time"
+)
+
+func testSuspensionPolicy(schedules ...Schedule) *SuspensionPolicy {
+	return &SuspensionPolicy{Enabled: Bool(true), Schedules: &schedules}
+}
+
+func testSchedule(scheduleType string, start string, end string, days ...string) Schedule {
+	return Schedule{Type: String(scheduleType), StartTime: String(start), EndTime: String(end), Days: &days}
+}
+
+func testEpochMillis(t time.Time) *float64 {
+	return Float64(float64(t.UnixNano() / int64(time.Millisecond)))
+}
+
+func TestSuspensionEvaluatorIsSuspended(t *testing.T) {
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, newYork)
+	}
+
+	weekdayHours := testSuspensionPolicy(testSchedule("WEEKLY", "09:00", "17:00", "MON"))
+	overnight := testSuspensionPolicy(testSchedule("DAILY", "22:00", "06:00"))
+	overnightFriday := testSuspensionPolicy(testSchedule("1", "22:00", "06:00", "FRIDAY"))
+	earlyMorning := testSuspensionPolicy(testSchedule("DAILY", "01:00", "03:00"))
+
+	disabled := testSuspensionPolicy(testSchedule("DAILY", "09:00", "17:00"))
+	disabled.Enabled = Bool(false)
+
+	blockedOut := testSuspensionPolicy(testSchedule("WEEKLY", "09:00", "17:00", "MON"))
+	blockedOut.BlockoutPeriods = &[]BlockoutPeriod{{
+		StartDate: testEpochMillis(at(time.October, 19, 12, 0)),
+		EndDate:   testEpochMillis(at(time.October, 19, 13, 0)),
+	}}
+
+	biweekly := testSuspensionPolicy(Schedule{Type: String("WEEKLY"), Days: &[]string{"MON"}, StartTime: String("09:00"), EndTime: String("17:00"), Repeats: String("BIWEEKLY")})
+	biweekly.Created = testEpochMillis(at(time.October, 5, 8, 0))
+
+	tests := []struct {
+		name   string
+		policy *SuspensionPolicy
+		t      time.Time
+		want   bool
+	}{
+		{"weekly inside", weekdayHours, at(time.October, 19, 10, 0), true},
+		{"weekly at start", weekdayHours, at(time.October, 19, 9, 0), true},
+		{"weekly before start", weekdayHours, at(time.October, 19, 8, 59), false},
+		{"weekly at end", weekdayHours, at(time.October, 19, 17, 0), false},
+		{"weekly other day", weekdayHours, at(time.October, 20, 10, 0), false},
+		{"overnight evening", overnight, at(time.October, 19, 23, 0), true},
+		{"overnight next morning", overnight, at(time.October, 20, 5, 59), true},
+		{"overnight at end", overnight, at(time.October, 20, 6, 0), false},
+		{"overnight daytime", overnight, at(time.October, 20, 12, 0), false},
+		{"overnight friday into saturday", overnightFriday, at(time.October, 24, 3, 0), true},
+		{"overnight friday not thursday night", overnightFriday, at(time.October, 23, 3, 0), false},
+		{"spring forward inside", earlyMorning, at(time.March, 8, 1, 30), true},
+		{"spring forward after end", earlyMorning, at(time.March, 8, 3, 0), false},
+		{"fall back second 1:30", earlyMorning, at(time.November, 1, 1, 30).Add(time.Hour), true},
+		{"fall back at end", earlyMorning, at(time.November, 1, 3, 0), false},
+		{"disabled", disabled, at(time.October, 19, 10, 0), false},
+		{"blockout", blockedOut, at(time.October, 19, 12, 30), false},
+		{"around blockout", blockedOut, at(time.October, 19, 11, 0), true},
+		{"biweekly anchor week", biweekly, at(time.October, 5, 10, 0), true},
+		{"biweekly off week", biweekly, at(time.October, 12, 10, 0), false},
+		{"biweekly two weeks later", biweekly, at(time.October, 19, 10, 0), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			e, err := NewSuspensionEvaluator(test.policy, newYork)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := e.IsSuspended(test.t); got != test.want {
+				t.Errorf("IsSuspended(%s) = %v, want %v", test.t, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSuspensionEvaluatorDSTWindows(t *testing.T) {
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	e, err := NewSuspensionEvaluator(testSuspensionPolicy(testSchedule("DAILY", "01:00", "03:00")), newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		day  time.Time
+		want time.Duration
+	}{
+		{"regular day", time.Date(2026, time.October, 19, 0, 0, 0, 0, newYork), 2 * time.Hour},
+		{"spring forward", time.Date(2026, time.March, 8, 0, 0, 0, 0, newYork), time.Hour},
+		{"fall back", time.Date(2026, time.November, 1, 0, 0, 0, 0, newYork), 3 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			windows := e.Windows(test.day, test.day.AddDate(0, 0, 1))
+			if len(windows) != 1 {
+				t.Fatalf("Windows = %v, want one window", windows)
+			}
+
+			if got := windows[0].End.Sub(windows[0].Start); got != test.want {
+				t.Errorf("window lasts %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSuspensionEvaluatorNext(t *testing.T) {
+
+	at := func(day int, hour int) time.Time {
+		return time.Date(2026, time.October, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name        string
+		policy      *SuspensionPolicy
+		t           time.Time
+		wantSuspend time.Time
+		wantResume  time.Time
+		resumes     bool
+	}{
+		{"overnight before window", testSuspensionPolicy(testSchedule("DAILY", "22:00", "06:00")), at(19, 10), at(19, 22), at(20, 6), true},
+		{"overnight inside window", testSuspensionPolicy(testSchedule("DAILY", "22:00", "06:00")), at(19, 23), at(20, 22), at(20, 6), true},
+		{"weekly", testSuspensionPolicy(testSchedule("WEEKLY", "09:00", "17:00", "MON")), at(19, 18), at(26, 9), at(26, 17), true},
+		{"continuous", testSuspensionPolicy(testSchedule("DAILY", "00:00", "00:00")), at(19, 10), time.Time{}, time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			e, err := NewSuspensionEvaluator(test.policy, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			suspend, ok := e.NextSuspend(test.t)
+			if ok != !test.wantSuspend.IsZero() || !suspend.Equal(test.wantSuspend) {
+				t.Errorf("NextSuspend = %s, %v, want %s", suspend, ok, test.wantSuspend)
+			}
+
+			resume, ok := e.NextResume(test.t)
+			if ok != test.resumes || !resume.Equal(test.wantResume) {
+				t.Errorf("NextResume = %s, %v, want %s, %v", resume, ok, test.wantResume, test.resumes)
+			}
+		})
+	}
+}
+
+func TestNewSuspensionEvaluatorErrors(t *testing.T) {
+
+	biweekly := testSchedule("WEEKLY", "09:00", "17:00", "MON")
+	biweekly.Repeats = String("2")
+
+	monthly := testSchedule("WEEKLY", "09:00", "17:00", "MON")
+	monthly.Repeats = String("MONTHLY")
+
+	missingEnd := testSuspensionPolicy(testSchedule("DAILY", "09:00", "17:00"))
+	missingEnd.BlockoutPeriods = &[]BlockoutPeriod{{StartDate: Float64(0)}}
+
+	tests := []struct {
+		name   string
+		policy *SuspensionPolicy
+	}{
+		{"unsupported type", testSuspensionPolicy(testSchedule("MONTHLY", "09:00", "17:00"))},
+		{"daily with days", testSuspensionPolicy(testSchedule("DAILY", "09:00", "17:00", "MON"))},
+		{"unknown day", testSuspensionPolicy(testSchedule("WEEKLY", "09:00", "17:00", "FUNDAY"))},
+		{"invalid time", testSuspensionPolicy(testSchedule("WEEKLY", "9am", "17:00", "MON"))},
+		{"unsupported repeats", testSuspensionPolicy(monthly)},
+		{"repeats without created", testSuspensionPolicy(biweekly)},
+		{"blockout without end", missingEnd},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewSuspensionEvaluator(test.policy, time.UTC); err == nil {
+				t.Error("NewSuspensionEvaluator succeeded, want an error")
+			}
+		})
+	}
+}
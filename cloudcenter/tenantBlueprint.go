@@ -0,0 +1,733 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenantBlueprint describes a tenant and the resources to create inside it.
+//
+// Resources are created in dependency order: tenant, clouds, regions, cloud
+// accounts, roles, groups, bundles, plans, contracts and activation profiles.
+// TenantId and CloudId fields are filled in automatically. Any other string
+// field may refer to a resource created earlier in the blueprint with a
+// placeholder of the form ${kind:name}, for example "${plan:Gold}",
+// "${role:Developers}", "${region:AWS/us-east-1}" or "${account:AWS/prod}".
+// In blueprints read by LoadTenantBlueprint, numeric and boolean fields may
+// hold a placeholder too.
+type TenantBlueprint struct {
+	Tenant             Tenant              `json:"tenant"`
+	Clouds             []CloudBlueprint    `json:"clouds,omitempty"`
+	Roles              []Role              `json:"roles,omitempty"`
+	Groups             []Group             `json:"groups,omitempty"`
+	Bundles            []Bundle            `json:"bundles,omitempty"`
+	Plans              []Plan              `json:"plans,omitempty"`
+	Contracts          []Contract          `json:"contracts,omitempty"`
+	ActivationProfiles []ActivationProfile `json:"activationProfiles,omitempty"`
+
+	// deferred holds the placeholders read into non-string fields, keyed by
+	// the JSON path of the field, e.g. "tenant.parentTenantId".
+	deferred map[string]string
+}
+
+type CloudBlueprint struct {
+	Cloud    Cloud          `json:"cloud"`
+	Regions  []CloudRegion  `json:"regions,omitempty"`
+	Accounts []CloudAccount `json:"accounts,omitempty"`
+}
+
+type TenantBlueprintOptions struct {
+
+	// PollInterval is the delay between operation status checks.
+	PollInterval time.Duration
+
+	// Output, when set, receives a line for every resource created or rolled back.
+	Output io.Writer
+}
+
+// TenantBlueprintResult maps every created resource, keyed "kind:name" as
+// used in placeholders, to its new id.
+type TenantBlueprintResult struct {
+	TenantId string
+	Ids      map[string]string
+}
+
+var blueprintPlaceholder = regexp.MustCompile(`\$\{([A-Za-z]+):([^}]+)\}`)
+
+// LoadTenantBlueprint reads a blueprint from a JSON or YAML (.yaml, .yml) file.
+// Scalars of any type are accepted for scalar fields, so ids may be written
+// as unquoted numbers and placeholders may be used in numeric and boolean
+// fields.
+func LoadTenantBlueprint(filename string) (*TenantBlueprint, error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+
+	err = unmarshalByExtension(filename, b, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	deferred := make(map[string]string)
+
+	j, err := json.Marshal(coerceBlueprintValue(doc, reflect.TypeOf(TenantBlueprint{}), "", deferred))
+	if err != nil {
+		return nil, err
+	}
+
+	var blueprint TenantBlueprint
+
+	err = json.Unmarshal(j, &blueprint)
+	if err != nil {
+		return nil, err
+	}
+
+	blueprint.deferred = deferred
+
+	return &blueprint, nil
+}
+
+type blueprintApplier struct {
+	client   *Client
+	ctx      context.Context
+	opts     TenantBlueprintOptions
+	result   *TenantBlueprintResult
+	rollback []func() error
+	names    []string
+	deferred map[string]string
+}
+
+// ApplyTenantBlueprint creates the tenant and all resources described by the
+// blueprint. If any step fails, the resources already created are deleted in
+// reverse order and the original error is returned.
+func (s *Client) ApplyTenantBlueprint(ctx context.Context, blueprint *TenantBlueprint, opts TenantBlueprintOptions) (*TenantBlueprintResult, error) {
+
+	// work on a deep copy so resolving placeholders leaves the caller's
+	// blueprint untouched
+	j, err := json.Marshal(blueprint)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied TenantBlueprint
+
+	err = json.Unmarshal(j, &copied)
+	if err != nil {
+		return nil, err
+	}
+
+	copied.deferred = blueprint.deferred
+
+	a := &blueprintApplier{
+		client: s,
+		ctx:    ctx,
+		opts:   opts,
+		result: &TenantBlueprintResult{Ids: make(map[string]string)},
+	}
+
+	err = a.apply(&copied)
+	if err != nil {
+		if rollbackErr := a.undo(); rollbackErr != nil {
+			return nil, fmt.Errorf("%s (rollback failed: %s)", err, rollbackErr)
+		}
+		return nil, err
+	}
+
+	return a.result, nil
+}
+
+func (a *blueprintApplier) apply(blueprint *TenantBlueprint) error {
+
+	s := a.client
+
+	a.deferred = blueprint.deferred
+
+	tenant := blueprint.Tenant
+	if err := a.resolve(&tenant, "tenant"); err != nil {
+		return err
+	}
+
+	newTenant, err := s.AddTenantWithContext(a.ctx, &tenant)
+	if err != nil {
+		return fmt.Errorf("Creating tenant %s failed: %s", stringValue(tenant.Name), err)
+	}
+
+	tenantId := stringValue(newTenant.Id)
+
+	a.result.TenantId = tenantId
+	a.created("tenant:"+stringValue(tenant.Name), tenantId, func() error {
+		id, err := strconv.Atoi(tenantId)
+		if err != nil {
+			return err
+		}
+		// the rollback runs after a.ctx failed or was cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+		defer cancel()
+		return s.deleteTenantAndWait(ctx, id, a.opts.PollInterval)
+	})
+
+	tenantIdInt, err := strconv.Atoi(tenantId)
+	if err != nil {
+		return err
+	}
+
+	for i, cloudBlueprint := range blueprint.Clouds {
+
+		path := "clouds." + strconv.Itoa(i)
+
+		cloud := cloudBlueprint.Cloud
+		cloud.TenantId = String(tenantId)
+		if err := a.resolve(&cloud, path+".cloud"); err != nil {
+			return err
+		}
+
+		newCloud, err := s.AddCloud(&cloud)
+		if err != nil {
+			return fmt.Errorf("Creating cloud %s failed: %s", stringValue(cloud.Name), err)
+		}
+
+		cloudName := stringValue(cloud.Name)
+		cloudId := stringValue(newCloud.Id)
+		cloudIdInt, err := strconv.Atoi(cloudId)
+		if err != nil {
+			return err
+		}
+
+		a.created("cloud:"+cloudName, cloudId, func() error {
+			return s.DeleteCloud(tenantIdInt, cloudIdInt)
+		})
+
+		for j, region := range cloudBlueprint.Regions {
+
+			region.TenantId = String(tenantId)
+			region.CloudId = String(cloudId)
+			if err := a.resolve(&region, path+".regions."+strconv.Itoa(j)); err != nil {
+				return err
+			}
+
+			newRegion, err := s.AddCloudRegion(&region)
+			if err != nil {
+				return fmt.Errorf("Creating region %s failed: %s", stringValue(region.DisplayName), err)
+			}
+
+			regionId := stringValue(newRegion.Id)
+			regionIdInt, err := strconv.Atoi(regionId)
+			if err != nil {
+				return err
+			}
+
+			a.created("region:"+cloudName+"/"+stringValue(region.DisplayName), regionId, func() error {
+				return s.DeleteCloudRegion(tenantIdInt, cloudIdInt, regionIdInt)
+			})
+		}
+
+		for j, account := range cloudBlueprint.Accounts {
+
+			account.TenantId = String(tenantId)
+			account.CloudId = String(cloudId)
+			if err := a.resolve(&account, path+".accounts."+strconv.Itoa(j)); err != nil {
+				return err
+			}
+
+			newAccount, err := s.addCloudAccountAndWait(a.ctx, &account, a.opts.PollInterval)
+			if err != nil {
+				return fmt.Errorf("Creating cloud account %s failed: %s", stringValue(account.DisplayName), err)
+			}
+
+			accountId := stringValue(newAccount.Id)
+			accountIdInt, err := strconv.Atoi(accountId)
+			if err != nil {
+				return err
+			}
+
+			a.created("account:"+cloudName+"/"+stringValue(account.DisplayName), accountId, func() error {
+				return s.DeleteCloudAccount(tenantIdInt, cloudIdInt, accountIdInt)
+			})
+		}
+	}
+
+	for i, role := range blueprint.Roles {
+
+		role.TenantId = String(tenantId)
+		if err := a.resolve(&role, "roles."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newRole, err := s.AddRole(&role)
+		if err != nil {
+			return fmt.Errorf("Creating role %s failed: %s", stringValue(role.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newRole.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("role:"+stringValue(role.Name), stringValue(newRole.Id), func() error {
+			return s.DeleteRole(tenantIdInt, id)
+		})
+	}
+
+	for i, group := range blueprint.Groups {
+
+		group.TenantId = String(tenantId)
+		if err := a.resolve(&group, "groups."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newGroup, err := s.AddGroup(&group)
+		if err != nil {
+			return fmt.Errorf("Creating group %s failed: %s", stringValue(group.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newGroup.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("group:"+stringValue(group.Name), stringValue(newGroup.Id), func() error {
+			return s.DeleteGroup(tenantIdInt, id)
+		})
+	}
+
+	for i, bundle := range blueprint.Bundles {
+
+		bundle.TenantId = String(tenantId)
+		if err := a.resolve(&bundle, "bundles."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newBundle, err := s.AddBundle(&bundle)
+		if err != nil {
+			return fmt.Errorf("Creating bundle %s failed: %s", stringValue(bundle.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newBundle.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("bundle:"+stringValue(bundle.Name), stringValue(newBundle.Id), func() error {
+			return s.DeleteBundle(tenantIdInt, id)
+		})
+	}
+
+	for i, plan := range blueprint.Plans {
+
+		plan.TenantId = String(tenantId)
+		if err := a.resolve(&plan, "plans."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newPlan, err := s.AddPlan(&plan)
+		if err != nil {
+			return fmt.Errorf("Creating plan %s failed: %s", stringValue(plan.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newPlan.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("plan:"+stringValue(plan.Name), stringValue(newPlan.Id), func() error {
+			return s.DeletePlan(tenantIdInt, id)
+		})
+	}
+
+	for i, contract := range blueprint.Contracts {
+
+		contract.TenantId = String(tenantId)
+		if err := a.resolve(&contract, "contracts."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newContract, err := s.AddContract(&contract)
+		if err != nil {
+			return fmt.Errorf("Creating contract %s failed: %s", stringValue(contract.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newContract.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("contract:"+stringValue(contract.Name), stringValue(newContract.Id), func() error {
+			return s.DeleteContract(tenantIdInt, id)
+		})
+	}
+
+	for i, profile := range blueprint.ActivationProfiles {
+
+		profile.TenantId = Int64(int64(tenantIdInt))
+		if err := a.resolve(&profile, "activationProfiles."+strconv.Itoa(i)); err != nil {
+			return err
+		}
+
+		newProfile, err := s.AddActivationProfile(&profile)
+		if err != nil {
+			return fmt.Errorf("Creating activation profile %s failed: %s", stringValue(profile.Name), err)
+		}
+
+		id, err := strconv.Atoi(stringValue(newProfile.Id))
+		if err != nil {
+			return err
+		}
+
+		a.created("activationProfile:"+stringValue(profile.Name), stringValue(newProfile.Id), func() error {
+			return s.DeleteActivationProfile(tenantIdInt, id)
+		})
+	}
+
+	return nil
+}
+
+func (a *blueprintApplier) created(key string, id string, undo func() error) {
+
+	a.result.Ids[key] = id
+	a.rollback = append(a.rollback, undo)
+	a.names = append(a.names, key)
+
+	if a.opts.Output != nil {
+		fmt.Fprintf(a.opts.Output, "Created %s [%s]\n", key, id)
+	}
+}
+
+// undo deletes every created resource in reverse order, continuing past
+// failures so as much as possible is cleaned up.
+func (a *blueprintApplier) undo() error {
+
+	var failures []string
+
+	for i := len(a.rollback) - 1; i >= 0; i-- {
+
+		if err := a.rollback[i](); err != nil {
+			failures = append(failures, a.names[i]+": "+err.Error())
+			continue
+		}
+
+		if a.opts.Output != nil {
+			fmt.Fprintf(a.opts.Output, "Rolled back %s\n", a.names[i])
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// resolve replaces ${kind:name} placeholders in every string field of v with
+// the id of the matching resource created earlier, then sets the non-string
+// fields that held a placeholder in the blueprint at path.
+func (a *blueprintApplier) resolve(v interface{}, path string) error {
+
+	if err := a.ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := resolvePlaceholders(reflect.ValueOf(v), a.result.Ids); err != nil {
+		return err
+	}
+
+	for fieldPath, placeholder := range a.deferred {
+
+		if !strings.HasPrefix(fieldPath, path+".") {
+			continue
+		}
+
+		id, err := expandBlueprintPlaceholders(placeholder, a.result.Ids)
+		if err != nil {
+			return err
+		}
+
+		err = setBlueprintField(reflect.ValueOf(v).Elem(), strings.Split(strings.TrimPrefix(fieldPath, path+"."), "."), id)
+		if err != nil {
+			return fmt.Errorf("Blueprint field %s: %s", fieldPath, err)
+		}
+	}
+
+	return nil
+}
+
+func resolvePlaceholders(v reflect.Value, ids map[string]string) error {
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return resolvePlaceholders(v.Elem(), ids)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := resolvePlaceholders(v.Field(i), ids); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolvePlaceholders(v.Index(i), ids); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		resolved, err := expandBlueprintPlaceholders(v.String(), ids)
+		if err != nil {
+			return err
+		}
+		v.SetString(resolved)
+	}
+
+	return nil
+}
+
+func expandBlueprintPlaceholders(value string, ids map[string]string) (string, error) {
+
+	var missing string
+
+	resolved := blueprintPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+		match := blueprintPlaceholder.FindStringSubmatch(placeholder)
+		id, ok := ids[match[1]+":"+match[2]]
+		if !ok {
+			missing = placeholder
+		}
+		return id
+	})
+
+	if missing != "" {
+		return "", errors.New("Blueprint reference " + missing + " does not match a resource created earlier")
+	}
+
+	return resolved, nil
+}
+
+// coerceBlueprintValue converts the scalars of a decoded JSON document to the
+// kinds of the fields of t they are read into. Numbers and booleans become
+// strings for string fields, and numeric strings numbers for numeric fields.
+// Placeholders in non-string fields are moved to deferred.
+func coerceBlueprintValue(v interface{}, t reflect.Type, path string, deferred map[string]string) interface{} {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		fields := jsonFields(t)
+		for key, item := range m {
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			m[key] = coerceBlueprintValue(item, field.Type, joinBlueprintPath(path, jsonFieldName(field)), deferred)
+		}
+
+	case reflect.Slice:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		for i, item := range items {
+			items[i] = coerceBlueprintValue(item, t.Elem(), joinBlueprintPath(path, strconv.Itoa(i)), deferred)
+		}
+
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for key, item := range m {
+			m[key] = coerceBlueprintValue(item, t.Elem(), joinBlueprintPath(path, key), deferred)
+		}
+
+	case reflect.String:
+		switch value := v.(type) {
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(value)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		value, ok := v.(string)
+		if !ok {
+			return v
+		}
+		if blueprintPlaceholder.MatchString(value) {
+			deferred[path] = value
+			return nil
+		}
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+
+	case reflect.Bool:
+		value, ok := v.(string)
+		if !ok {
+			return v
+		}
+		if blueprintPlaceholder.MatchString(value) {
+			deferred[path] = value
+			return nil
+		}
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+
+	return v
+}
+
+// setBlueprintField sets the field at path below v, allocating pointers on the
+// way, to value converted to the field's kind.
+func setBlueprintField(v reflect.Value, path []string, value string) error {
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if len(path) == 0 {
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.New(value + " is not an integer")
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return errors.New(value + " is not an unsigned integer")
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return errors.New(value + " is not a number")
+			}
+			v.SetFloat(f)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.New(value + " is not a boolean")
+			}
+			v.SetBool(b)
+		default:
+			return errors.New("field is not a scalar")
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+
+	case reflect.Struct:
+		field, ok := jsonFields(v.Type())[strings.ToLower(path[0])]
+		if !ok {
+			return errors.New("unknown field " + path[0])
+		}
+		return setBlueprintField(v.FieldByIndex(field.Index), path[1:], value)
+
+	case reflect.Slice:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= v.Len() {
+			return errors.New("no element " + path[0])
+		}
+		return setBlueprintField(v.Index(i), path[1:], value)
+
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := setBlueprintField(elem, path[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+
+	return errors.New("no field " + path[0])
+}
+
+// jsonFields maps the lower-cased JSON names of the fields of t to the fields,
+// matching keys case-insensitively like encoding/json.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+
+	fields := make(map[string]reflect.StructField)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		fields[strings.ToLower(jsonFieldName(field))] = field
+	}
+
+	return fields
+}
+
+func jsonFieldName(field reflect.StructField) string {
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	return name
+}
+
+func joinBlueprintPath(path string, name string) string {
+
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,327 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DeleteTenantOptions struct {
+
+	// DryRun builds and returns the deletion plan without deleting anything.
+	DryRun bool
+
+	// Confirm, when set, is called with the plan before anything is deleted.
+	// Returning false aborts the deletion.
+	Confirm func(plan *TenantDeletionPlan) bool
+
+	// PollInterval is the delay between operation status checks.
+	PollInterval time.Duration
+
+	// Output, when set, receives a line for every job, user and tenant deleted.
+	Output io.Writer
+}
+
+// TenantDeletionStep lists everything removed for a single tenant.
+type TenantDeletionStep struct {
+	Tenant Tenant
+	Jobs   []Job
+	Users  []User
+}
+
+// TenantDeletionPlan holds the deletion steps ordered bottom-up, so each
+// sub-tenant is removed before its parent.
+type TenantDeletionPlan struct {
+	Steps []TenantDeletionStep
+}
+
+func (p *TenantDeletionPlan) String() string {
+
+	var b strings.Builder
+
+	for _, step := range p.Steps {
+
+		fmt.Fprintf(&b, "Tenant %s [%s]\n", stringValue(step.Tenant.Name), stringValue(step.Tenant.Id))
+
+		for _, job := range step.Jobs {
+			fmt.Fprintf(&b, "  terminate job %s [%s] owned by %s\n", stringValue(job.Name), stringValue(job.Id), stringValue(job.OwnerEmailAddress))
+		}
+
+		for _, user := range step.Users {
+			fmt.Fprintf(&b, "  delete user %s [%s]\n", stringValue(user.EmailAddr), stringValue(user.Id))
+		}
+
+		fmt.Fprintf(&b, "  delete tenant %s\n", stringValue(step.Tenant.Id))
+	}
+
+	return b.String()
+}
+
+// PlanTenantDeletion lists the sub-tenants, jobs and users that have to be
+// removed, in order, before tenantId itself can be deleted. Every page of
+// users and jobs is read.
+func (s *Client) PlanTenantDeletion(ctx context.Context, tenantId int) (*TenantDeletionPlan, error) {
+
+	tree, err := s.GetTenantTree(ctx, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.getAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	jobs, err := s.getAllJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	usersByTenant := make(map[string][]User)
+	tenantByEmail := make(map[string]string)
+
+	for _, user := range users {
+		userTenantId := stringValue(user.TenantId)
+		if user.EmailAddr != nil {
+			tenantByEmail[strings.ToLower(*user.EmailAddr)] = userTenantId
+		}
+		if !s.isCallingUser(user) {
+			usersByTenant[userTenantId] = append(usersByTenant[userTenantId], user)
+		}
+	}
+
+	jobsByTenant := make(map[string][]Job)
+
+	for _, job := range jobs {
+		if job.OwnerEmailAddress == nil || jobFinished(stringValue(job.Status)) {
+			continue
+		}
+		jobTenantId, ok := tenantByEmail[strings.ToLower(*job.OwnerEmailAddress)]
+		if ok {
+			jobsByTenant[jobTenantId] = append(jobsByTenant[jobTenantId], job)
+		}
+	}
+
+	plan := &TenantDeletionPlan{}
+
+	var walk func(node *TenantNode)
+	walk = func(node *TenantNode) {
+
+		for _, child := range node.Children {
+			walk(child)
+		}
+
+		id := stringValue(node.Tenant.Id)
+
+		// the tenant admin goes with the tenant
+		var tenantUsers []User
+		for _, user := range usersByTenant[id] {
+			if stringValue(user.Id) != stringValue(node.Tenant.UserId) {
+				tenantUsers = append(tenantUsers, user)
+			}
+		}
+
+		plan.Steps = append(plan.Steps, TenantDeletionStep{
+			Tenant: node.Tenant,
+			Jobs:   jobsByTenant[id],
+			Users:  tenantUsers,
+		})
+	}
+	walk(tree)
+
+	return plan, nil
+}
+
+// DeleteTenantRecursive deletes a tenant and all of its sub-tenants, bottom-up.
+// For each tenant the running jobs are terminated and the users deleted before
+// the tenant itself, waiting for every asynchronous operation to complete.
+// Finished jobs are skipped. The tenant admin is removed with its tenant and
+// the calling user is never deleted.
+func (s *Client) DeleteTenantRecursive(ctx context.Context, tenantId int, opts DeleteTenantOptions) (*TenantDeletionPlan, error) {
+
+	plan, err := s.PlanTenantDeletion(ctx, tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	if opts.Confirm != nil && !opts.Confirm(plan) {
+		return plan, errors.New("Tenant deletion was not confirmed")
+	}
+
+	for _, step := range plan.Steps {
+
+		for _, job := range step.Jobs {
+
+			if err := ctx.Err(); err != nil {
+				return plan, err
+			}
+
+			jobId, err := strconv.Atoi(stringValue(job.Id))
+			if err != nil {
+				return plan, err
+			}
+
+			operation, err := s.DeleteJobAsync(jobId)
+			if err != nil {
+				return plan, fmt.Errorf("Terminating job %d failed: %s", jobId, err)
+			}
+
+			_, err = s.WaitForOperation(ctx, operation, opts.PollInterval)
+			if err != nil {
+				return plan, fmt.Errorf("Terminating job %d failed: %s", jobId, err)
+			}
+
+			if opts.Output != nil {
+				fmt.Fprintf(opts.Output, "Terminated job %s [%d]\n", stringValue(job.Name), jobId)
+			}
+		}
+
+		for _, user := range step.Users {
+
+			if err := ctx.Err(); err != nil {
+				return plan, err
+			}
+
+			userId, err := strconv.Atoi(stringValue(user.Id))
+			if err != nil {
+				return plan, err
+			}
+
+			err = s.DeleteUser(userId)
+			if err != nil {
+				return plan, fmt.Errorf("Deleting user %d failed: %s", userId, err)
+			}
+
+			if opts.Output != nil {
+				fmt.Fprintf(opts.Output, "Deleted user %s [%d]\n", stringValue(user.EmailAddr), userId)
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return plan, err
+		}
+
+		id, err := strconv.Atoi(stringValue(step.Tenant.Id))
+		if err != nil {
+			return plan, err
+		}
+
+		err = s.deleteTenantAndWait(ctx, id, opts.PollInterval)
+		if err != nil {
+			return plan, fmt.Errorf("Deleting tenant %d failed: %s", id, err)
+		}
+
+		if opts.Output != nil {
+			fmt.Fprintf(opts.Output, "Deleted tenant %s [%d]\n", stringValue(step.Tenant.Name), id)
+		}
+	}
+
+	return plan, nil
+}
+
+// deleteTenantAndWait deletes the tenant and waits until it is gone. When the
+// deletion is accepted without an operation to poll, the tenant is polled
+// until it is no longer found. A ctx without a deadline is bounded by
+// defaultOperationTimeout.
+func (s *Client) deleteTenantAndWait(ctx context.Context, tenantId int, pollInterval time.Duration) error {
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultOperationTimeout)
+		defer cancel()
+	}
+
+	operation, err := s.DeleteTenantAsync(tenantId)
+	if err != nil && !deleteTenantAccepted(err) {
+		return err
+	}
+
+	if err == nil && !(nonzero(operation.Id) && nonzero(operation.OperationId) && nonzero(operation.ResourceUrl)) {
+		_, err = s.WaitForOperation(ctx, operation, pollInterval)
+		return err
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	for {
+		_, err := s.GetTenant(tenantId)
+		if isNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// deleteTenantAccepted reports whether err is the "Delete tenant request
+// accepted" reply DeleteTenantSync also handles.
+func deleteTenantAccepted(err error) bool {
+
+	statusErr, ok := err.(*statusError)
+	if !ok {
+		return false
+	}
+
+	var reply OperationStatus
+	if json.Unmarshal(statusErr.Body, &reply) != nil {
+		return false
+	}
+
+	return stringValue(reply.Msg) == "Delete tenant request accepted"
+}
+
+// jobFinished reports whether a job with the given status no longer needs to
+// be terminated.
+func jobFinished(status string) bool {
+
+	switch status {
+	case "JobTerminated", "JobCanceled", "JobRejected":
+		return true
+	}
+
+	return false
+}
+
+// isCallingUser reports whether user is the one the client authenticates as.
+func (s *Client) isCallingUser(user User) bool {
+
+	return strings.EqualFold(stringValue(user.Username), s.Username) || strings.EqualFold(stringValue(user.EmailAddr), s.Username)
+}
@@ -0,0 +1,223 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// PreferencePasswordMinLength is the tenant preference holding the minimum
+// length of user passwords, as set in example/example_add.go.
+const PreferencePasswordMinLength = "PASSWORD_MIN_LENGTH"
+
+// ErrTenantPreferencesChanged is returned when the tenant keeps changing
+// between the read and the write of a preference update.
+var ErrTenantPreferencesChanged = errors.New("Tenant was modified concurrently, please retry")
+
+// preferenceUpdateAttempts is how many times a preference update is retried
+// when the tenant changed after it was read.
+const preferenceUpdateAttempts = 3
+
+func (s *Client) GetTenantPreference(tenantId int, name string) (*Preference, error) {
+
+	tenant, err := s.GetTenant(tenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	if tenant.Preferences != nil {
+		for _, preference := range *tenant.Preferences {
+			if stringValue(preference.Name) == name {
+				return &preference, nil
+			}
+		}
+	}
+
+	return nil, errors.New("PREFERENCE NOT FOUND")
+}
+
+func (s *Client) GetTenantPreferenceInt(tenantId int, name string) (int, error) {
+
+	preference, err := s.GetTenantPreference(tenantId, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(stringValue(preference.Value))
+}
+
+func (s *Client) GetTenantPreferenceBool(tenantId int, name string) (bool, error) {
+
+	preference, err := s.GetTenantPreference(tenantId, name)
+	if err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(stringValue(preference.Value))
+}
+
+func (s *Client) SetTenantPreferenceInt(tenantId int, name string, value int) (*Tenant, error) {
+	return s.SetTenantPreferences(tenantId, []Preference{
+		{Name: String(name), Value: String(strconv.Itoa(value))},
+	})
+}
+
+func (s *Client) SetTenantPreferenceBool(tenantId int, name string, value bool) (*Tenant, error) {
+	return s.SetTenantPreferences(tenantId, []Preference{
+		{Name: String(name), Value: String(strconv.FormatBool(value))},
+	})
+}
+
+func (s *Client) GetTenantPasswordMinLength(tenantId int) (int, error) {
+	return s.GetTenantPreferenceInt(tenantId, PreferencePasswordMinLength)
+}
+
+func (s *Client) SetTenantPasswordMinLength(tenantId int, length int) (*Tenant, error) {
+	return s.SetTenantPreferenceInt(tenantId, PreferencePasswordMinLength, length)
+}
+
+// SetTenantPreferences merges preferences into the tenant's preferences by
+// name, adding new ones and replacing the value of existing ones. Preferences
+// not mentioned are left untouched.
+func (s *Client) SetTenantPreferences(tenantId int, preferences []Preference) (*Tenant, error) {
+
+	for _, preference := range preferences {
+		if nonzero(preference.Name) {
+			return nil, errors.New("Preference.Name is missing")
+		}
+	}
+
+	return s.modifyTenantPreferences(tenantId, func(current []Preference) []Preference {
+
+		for _, preference := range preferences {
+
+			found := false
+
+			for i := range current {
+				if stringValue(current[i].Name) == *preference.Name {
+					current[i].Value = preference.Value
+					found = true
+				}
+			}
+
+			if !found {
+				current = append(current, preference)
+			}
+		}
+
+		return current
+	})
+}
+
+func (s *Client) DeleteTenantPreference(tenantId int, name string) (*Tenant, error) {
+
+	return s.modifyTenantPreferences(tenantId, func(current []Preference) []Preference {
+
+		var remaining []Preference
+
+		for _, preference := range current {
+			if stringValue(preference.Name) != name {
+				remaining = append(remaining, preference)
+			}
+		}
+
+		return remaining
+	})
+}
+
+// modifyTenantPreferences reads the tenant, applies modify to a copy of its
+// preferences and writes the tenant back with the result. Just before the
+// write the tenant is read again; if it changed in the meantime, preferences or
+// any other field, the update starts over from the new read, at most
+// preferenceUpdateAttempts times. Nothing is written when the preferences are
+// unchanged.
+func (s *Client) modifyTenantPreferences(tenantId int, modify func(current []Preference) []Preference) (*Tenant, error) {
+
+	for attempt := 0; attempt < preferenceUpdateAttempts; attempt++ {
+
+		current, err := s.GetTenant(tenantId)
+		if err != nil {
+			return nil, err
+		}
+
+		original := toJSONMap(current)
+		updated := modify(copyPreferences(current.Preferences))
+
+		if equalPreferences(copyPreferences(current.Preferences), updated) {
+			return current, nil
+		}
+
+		latest, err := s.GetTenant(tenantId)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(original, toJSONMap(latest)) {
+			continue
+		}
+
+		if updated == nil {
+			updated = []Preference{}
+		}
+		current.Preferences = &updated
+
+		return s.UpdateTenant(current)
+	}
+
+	return nil, ErrTenantPreferencesChanged
+}
+
+func copyPreferences(preferences *[]Preference) []Preference {
+
+	if preferences == nil {
+		return nil
+	}
+
+	copied := make([]Preference, 0, len(*preferences))
+
+	for _, preference := range *preferences {
+		copied = append(copied, Preference{
+			Name:  String(stringValue(preference.Name)),
+			Value: String(stringValue(preference.Value)),
+		})
+	}
+
+	return copied
+}
+
+func equalPreferences(a []Preference, b []Preference) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	values := make(map[string]string, len(a))
+	for _, preference := range a {
+		values[stringValue(preference.Name)] = stringValue(preference.Value)
+	}
+
+	for _, preference := range b {
+		value, ok := values[stringValue(preference.Name)]
+		if !ok || value != stringValue(preference.Value) {
+			return false
+		}
+	}
+
+	return true
+}
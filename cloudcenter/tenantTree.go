@@ -0,0 +1,314 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TenantTreeText = "text"
+	TenantTreeJSON = "json"
+	TenantTreeDOT  = "dot"
+)
+
+// TenantNode is a tenant together with its sub-tenants. UserCount, Clouds and
+// Plans are only populated by AttachTenantTreeDetails.
+type TenantNode struct {
+	Tenant    Tenant        `json:"tenant"`
+	Depth     int           `json:"depth"`
+	UserCount *int          `json:"userCount,omitempty"`
+	Clouds    []Cloud       `json:"clouds,omitempty"`
+	Plans     []Plan        `json:"plans,omitempty"`
+	Children  []*TenantNode `json:"children,omitempty"`
+}
+
+type TenantTreeDetails struct {
+	UserCounts bool
+	Clouds     bool
+	Plans      bool
+}
+
+// GetTenantTree returns the tenant rootId and every tenant below it, linked
+// through Tenant.ParentTenantId.
+func (s *Client) GetTenantTree(ctx context.Context, rootId int) (*TenantNode, error) {
+
+	tenants, err := s.getAllTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var root *Tenant
+	children := make(map[int64][]Tenant)
+
+	for i := range tenants {
+
+		tenant := tenants[i]
+
+		if stringValue(tenant.Id) == strconv.Itoa(rootId) {
+			root = &tenant
+		}
+
+		if tenant.ParentTenantId != nil {
+			children[*tenant.ParentTenantId] = append(children[*tenant.ParentTenantId], tenant)
+		}
+	}
+
+	if root == nil {
+		root, err = s.GetTenant(rootId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	visited := make(map[string]bool)
+
+	var build func(tenant Tenant, depth int) (*TenantNode, error)
+	build = func(tenant Tenant, depth int) (*TenantNode, error) {
+
+		id := stringValue(tenant.Id)
+		if visited[id] {
+			return nil, errors.New("Tenant hierarchy contains a cycle at tenant " + id)
+		}
+		visited[id] = true
+
+		node := &TenantNode{Tenant: tenant, Depth: depth}
+
+		tenantId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		subTenants := children[tenantId]
+		sort.Slice(subTenants, func(i, j int) bool {
+			return stringValue(subTenants[i].Name) < stringValue(subTenants[j].Name)
+		})
+
+		for _, subTenant := range subTenants {
+			child, err := build(subTenant, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+
+		return node, nil
+	}
+
+	return build(*root, 0)
+}
+
+// AttachTenantTreeDetails populates the requested per-tenant details on every
+// node of the tree.
+func (s *Client) AttachTenantTreeDetails(ctx context.Context, root *TenantNode, details TenantTreeDetails) error {
+
+	userCounts := make(map[string]int)
+
+	if details.UserCounts {
+		users, err := s.getAllUsers()
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			userCounts[stringValue(user.TenantId)]++
+		}
+	}
+
+	return root.WalkDepthFirst(func(node *TenantNode) error {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		id := stringValue(node.Tenant.Id)
+
+		if details.UserCounts {
+			node.UserCount = Int(userCounts[id])
+		}
+
+		if !details.Clouds && !details.Plans {
+			return nil
+		}
+
+		tenantId, err := strconv.Atoi(id)
+		if err != nil {
+			return err
+		}
+
+		if details.Clouds {
+			node.Clouds, err = s.GetClouds(tenantId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if details.Plans {
+			node.Plans, err = s.GetPlans(tenantId)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// WalkDepthFirst calls fn for the node and then each of its sub-trees in
+// pre-order. Returning an error from fn stops the walk.
+func (n *TenantNode) WalkDepthFirst(fn func(node *TenantNode) error) error {
+
+	if err := fn(n); err != nil {
+		return err
+	}
+
+	for _, child := range n.Children {
+		if err := child.WalkDepthFirst(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WalkBreadthFirst calls fn for every node level by level, starting with n.
+// Returning an error from fn stops the walk.
+func (n *TenantNode) WalkBreadthFirst(fn func(node *TenantNode) error) error {
+
+	queue := []*TenantNode{n}
+
+	for len(queue) > 0 {
+
+		node := queue[0]
+		queue = queue[1:]
+
+		if err := fn(node); err != nil {
+			return err
+		}
+
+		queue = append(queue, node.Children...)
+	}
+
+	return nil
+}
+
+// Render writes the tree as TenantTreeText, TenantTreeJSON or TenantTreeDOT.
+func (n *TenantNode) Render(w io.Writer, format string) error {
+
+	switch format {
+	case TenantTreeText:
+		return n.renderText(w, "", true, true)
+	case TenantTreeJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(n)
+	case TenantTreeDOT:
+		return n.renderDOT(w)
+	}
+
+	return errors.New("Unknown tenant tree format: " + format)
+}
+
+func (n *TenantNode) label() string {
+
+	label := stringValue(n.Tenant.Name) + " [" + stringValue(n.Tenant.Id) + "]"
+
+	var extra []string
+	if n.UserCount != nil {
+		extra = append(extra, fmt.Sprintf("users=%d", *n.UserCount))
+	}
+	if n.Clouds != nil {
+		extra = append(extra, fmt.Sprintf("clouds=%d", len(n.Clouds)))
+	}
+	if n.Plans != nil {
+		extra = append(extra, fmt.Sprintf("plans=%d", len(n.Plans)))
+	}
+
+	if len(extra) > 0 {
+		label += " " + strings.Join(extra, " ")
+	}
+
+	return label
+}
+
+func (n *TenantNode) renderText(w io.Writer, prefix string, root bool, last bool) error {
+
+	line := n.label()
+	childPrefix := ""
+
+	if !root {
+		if last {
+			line = prefix + "└── " + line
+			childPrefix = prefix + "    "
+		} else {
+			line = prefix + "├── " + line
+			childPrefix = prefix + "│   "
+		}
+	}
+
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return err
+	}
+
+	for i, child := range n.Children {
+		if err := child.renderText(w, childPrefix, false, i == len(n.Children)-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *TenantNode) renderDOT(w io.Writer) error {
+
+	if _, err := fmt.Fprintln(w, "digraph tenants {"); err != nil {
+		return err
+	}
+
+	err := n.WalkDepthFirst(func(node *TenantNode) error {
+
+		id := stringValue(node.Tenant.Id)
+
+		if _, err := fmt.Fprintf(w, "  \"%s\" [label=%s];\n", id, strconv.Quote(node.label())); err != nil {
+			return err
+		}
+
+		for _, child := range node.Children {
+			if _, err := fmt.Fprintf(w, "  \"%s\" -> \"%s\";\n", id, stringValue(child.Tenant.Id)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
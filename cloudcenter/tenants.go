@@ -18,6 +18,7 @@ package cloudcenter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -119,10 +120,23 @@ func (s *Client) GetTenant(id int) (*Tenant, error) {
 	return tenant, nil
 }
 
-func (s *Client) AddTenant(tenant *Tenant) error {
+// AddTenant creates the tenant and returns it, waiting up to
+// defaultOperationTimeout when the creation is accepted asynchronously.
+func (s *Client) AddTenant(tenant *Tenant) (*Tenant, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultOperationTimeout)
+	defer cancel()
+
+	return s.AddTenantWithContext(ctx, tenant)
+}
+
+// AddTenantWithContext creates the tenant and returns it. When the
+// creation is accepted asynchronously, the operation is waited on until it
+// finishes or ctx is done, and the tenant is then looked up by its short name.
+func (s *Client) AddTenantWithContext(ctx context.Context, tenant *Tenant) (*Tenant, error) {
 
 	if errs := validator.Validate(tenant); errs != nil {
-		return errs
+		return nil, errs
 	}
 
 	url := fmt.Sprintf(s.BaseURL + "/v1/tenants")
@@ -130,15 +144,58 @@ func (s *Client) AddTenant(tenant *Tenant) error {
 	j, err := json.Marshal(tenant)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(j))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = s.doRequest(req)
+	body, err := s.doRequest(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(body)) > 0 {
 
-	return err
+		var data struct {
+			Tenant
+			OperationId *string `json:"operationId,omitempty"`
+			Status      *string `json:"status,omitempty"`
+			Msg         *string `json:"msg,omitempty"`
+		}
+
+		err = json.Unmarshal(body, &data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Tenant creation may be accepted asynchronously, in which case the
+		// response is an operation status rather than the tenant
+		if data.Status != nil {
+
+			operation := &OperationStatus{
+				Id:          data.Id,
+				OperationId: data.OperationId,
+				Status:      data.Status,
+				Msg:         data.Msg,
+			}
+
+			_, err = s.WaitForOperation(ctx, operation, defaultPollInterval)
+
+			if err != nil {
+				return nil, err
+			}
+
+		} else if !nonzero(data.Id) {
+
+			newTenant := data.Tenant
+			return &newTenant, nil
+		}
+	}
+
+	return s.GetTenantByShortName(*tenant.ShortName)
 }
 
 func (s *Client) UpdateTenant(tenant *Tenant) (*Tenant, error) {
@@ -241,3 +298,72 @@ func (s *Client) DeleteTenantAsync(tenantId int) (*OperationStatus, error) {
 
 	return &data, nil
 }
+
+func (s *Client) GetTenantByShortName(shortName string) (*Tenant, error) {
+
+	return s.findTenant(func(tenant Tenant) bool {
+		return stringValue(tenant.ShortName) == shortName
+	})
+}
+
+func (s *Client) GetTenantByName(name string) (*Tenant, error) {
+
+	return s.findTenant(func(tenant Tenant) bool {
+		return stringValue(tenant.Name) == name
+	})
+}
+
+func (s *Client) GetTenantByExternalId(externalId string) (*Tenant, error) {
+
+	return s.findTenant(func(tenant Tenant) bool {
+		return stringValue(tenant.ExternalId) == externalId
+	})
+}
+
+func (s *Client) findTenant(match func(tenant Tenant) bool) (*Tenant, error) {
+
+	tenants, err := s.getAllTenants()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tenant := range tenants {
+		if match(tenant) {
+			return &tenant, nil
+		}
+	}
+
+	return nil, errors.New("TENANT NOT FOUND")
+}
+
+// getAllTenants returns the tenants from every page of /v1/tenants, where
+// GetTenants only returns the first page.
+func (s *Client) getAllTenants() ([]Tenant, error) {
+
+	var tenants []Tenant
+
+	for page := 0; ; page++ {
+
+		url := fmt.Sprintf(s.BaseURL + "/v1/tenants?page=" + strconv.Itoa(page))
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var data TenantAPIResponse
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		tenants = append(tenants, data.Tenants...)
+
+		if len(data.Tenants) == 0 || data.TotalPages == nil || page+1 >= *data.TotalPages {
+			return tenants, nil
+		}
+	}
+}
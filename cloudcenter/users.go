@@ -89,6 +89,38 @@ func (s *Client) GetUsers() ([]User, error) {
 	return users, nil
 }
 
+// getAllUsers pages through the users, as GetUsers only returns the first
+// page.
+func (s *Client) getAllUsers() ([]User, error) {
+
+	var users []User
+
+	for page := 0; ; page++ {
+
+		url := fmt.Sprintf(s.BaseURL + "/v1/users?page=" + strconv.Itoa(page))
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var data UserAPIResponse
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, data.Users...)
+
+		if len(data.Users) == 0 || page+1 >= data.TotalPages {
+			return users, nil
+		}
+	}
+}
+
 func (s *Client) GetUser(id int) (*User, error) {
 
 	url := fmt.Sprintf(s.BaseURL + "/v1/users/" + strconv.Itoa(id))
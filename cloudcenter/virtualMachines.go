@@ -16,6 +16,7 @@ or implied.
 
 package cloudcenter
 
+import "context"
 import "fmt"
 import "net/http"
 import "strconv"
@@ -193,6 +194,46 @@ func (s *Client) GetVirtualMachines() ([]VirtualMachineDetails, error) {
 	return virtualMachine, nil
 }
 
+// getAllVirtualMachines pages through the virtual machines, as
+// GetVirtualMachines only returns the first page.
+func (s *Client) getAllVirtualMachines(ctx context.Context) ([]VirtualMachineDetails, error) {
+
+	var virtualMachines []VirtualMachineDetails
+
+	for page := 0; ; page++ {
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		url := fmt.Sprintf(s.BaseURL + "/v1/virtualMachines?page=" + strconv.Itoa(page))
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		bytes, err := s.doRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		var data VirtualMachineAPIResponse
+
+		err = json.Unmarshal(bytes, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		if data.Details == nil {
+			return virtualMachines, nil
+		}
+
+		virtualMachines = append(virtualMachines, data.Details.VirtualMachineDetails...)
+
+		if len(data.Details.VirtualMachineDetails) == 0 || data.Details.TotalPages == nil || int64(page+1) >= *data.Details.TotalPages {
+			return virtualMachines, nil
+		}
+	}
+}
+
 func (s *Client) GetVirtualMachine(virtualMachineId int) (*VirtualMachineDetails, error) {
 
 	var data VirtualMachineDetails
@@ -0,0 +1,140 @@
+/*Copyright (c) 2019 Cisco and/or its affiliates.
+
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.0 (the "License"). You may obtain a copy of the
+License at
+
+               https://developer.cisco.com/docs/licenses
+
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+package cloudcenter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The library structs only carry json tags, so YAML documents are converted
+// to and from JSON rather than decoded directly. This keeps field names
+// identical in both formats.
+
+func unmarshalYAML(data []byte, v interface{}) error {
+
+	var doc interface{}
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	doc, err := yamlToJSONValue(doc)
+	if err != nil {
+		return err
+	}
+
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(j, v)
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber()
+
+	var doc interface{}
+
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(jsonToYAMLValue(doc))
+}
+
+// unmarshalByExtension decodes data as YAML when filename ends in .yaml or
+// .yml and as JSON otherwise.
+func unmarshalByExtension(filename string, data []byte, v interface{}) error {
+
+	if isYAMLFile(filename) {
+		return unmarshalYAML(data, v)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func isYAMLFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func yamlToJSONValue(v interface{}) (interface{}, error) {
+
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = converted
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range value {
+			converted, err := yamlToJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = converted
+		}
+		return value, nil
+	}
+
+	return v, nil
+}
+
+func jsonToYAMLValue(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonToYAMLValue(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonToYAMLValue(item)
+		}
+		return value
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	}
+
+	return v
+}